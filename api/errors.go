@@ -0,0 +1,40 @@
+package api
+
+import (
+	"encoding/json"
+	wof_writer "github.com/sfomuseum/go-www-geotag-whosonfirst/writer"
+	"net/http"
+	"os"
+)
+
+// writeError maps err to an HTTP status code and writes it to rsp. Errors that carry structured
+// information (for example validation errors) are written as JSON.
+func writeError(rsp http.ResponseWriter, err error) {
+
+	if os.IsNotExist(err) {
+		http.Error(rsp, "Not found", http.StatusNotFound)
+		return
+	}
+
+	switch e := err.(type) {
+	case *wof_writer.ValidationError:
+		writeJSON(rsp, http.StatusBadRequest, e)
+	case *wof_writer.NotAllowedError:
+		writeJSON(rsp, http.StatusForbidden, e)
+	case *wof_writer.RefusedError:
+		writeJSON(rsp, http.StatusConflict, e)
+	case *wof_writer.PreconditionFailedError:
+		writeJSON(rsp, http.StatusPreconditionFailed, e)
+	default:
+		http.Error(rsp, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(rsp http.ResponseWriter, status int, v interface{}) {
+
+	rsp.Header().Set("Content-Type", "application/json")
+	rsp.WriteHeader(status)
+
+	enc := json.NewEncoder(rsp)
+	enc.Encode(v)
+}
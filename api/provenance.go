@@ -0,0 +1,100 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/aaronland/go-http-sanitize"
+	wof_writer "github.com/sfomuseum/go-www-geotag-whosonfirst/writer"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// MAX_REQUEST_ID_LENGTH is the maximum length of a request ID supplied in an "X-Request-Id" header.
+const MAX_REQUEST_ID_LENGTH int = 128
+
+var re_request_id *regexp.Regexp
+
+func init() {
+	re_request_id = regexp.MustCompile(`^[a-zA-Z0-9_.:\-]+$`)
+}
+
+// provenanceFromRequest derives the provenance of a geotag from req. The author is the HTTP basic auth
+// user, if present, or the value of the header named by opts.AuthorHeader, if defined, or the "author" query
+// parameter. The "author" query parameter is supplied by the client and is not authenticated. The session ID
+// and image URL are read from the "session_id" and "image_url" query parameters. The request ID is the value
+// of the "X-Request-Id" header, which must be no longer than MAX_REQUEST_ID_LENGTH and only contain letters,
+// numbers, "_", ".", ":" and "-", or, if absent, a new random identifier.
+func provenanceFromRequest(req *http.Request, opts *WriterHandlerOptions) (*wof_writer.Provenance, error) {
+
+	author, _, ok := req.BasicAuth()
+
+	if !ok || author == "" {
+
+		if opts.AuthorHeader != "" {
+
+			author = strings.TrimSpace(req.Header.Get(opts.AuthorHeader))
+
+		} else {
+
+			str_author, err := sanitize.GetString(req, "author")
+
+			if err != nil {
+				return nil, err
+			}
+
+			author = str_author
+		}
+	}
+
+	session_id, err := sanitize.GetString(req, "session_id")
+
+	if err != nil {
+		return nil, err
+	}
+
+	image_url, err := sanitize.GetString(req, "image_url")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if image_url != "" {
+
+		u, err := url.Parse(image_url)
+
+		if err != nil || !u.IsAbs() || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("Invalid image_url parameter")
+		}
+	}
+
+	request_id := req.Header.Get("X-Request-Id")
+
+	if request_id != "" && (len(request_id) > MAX_REQUEST_ID_LENGTH || !re_request_id.MatchString(request_id)) {
+		return nil, fmt.Errorf("Invalid X-Request-Id header")
+	}
+
+	if request_id == "" {
+
+		b := make([]byte, 16)
+
+		_, err := rand.Read(b)
+
+		if err != nil {
+			return nil, err
+		}
+
+		request_id = hex.EncodeToString(b)
+	}
+
+	p := &wof_writer.Provenance{
+		Author:    author,
+		SessionId: session_id,
+		RequestId: request_id,
+		ImageURL:  image_url,
+	}
+
+	return p, nil
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProvenanceFromRequest(t *testing.T) {
+
+	tests := []struct {
+		Name         string
+		Query        string
+		BasicAuth    string
+		Header       string
+		AuthorHeader string
+		Author       string
+	}{
+		{"Query parameter", "author=alice", "", "", "", "alice"},
+		{"Basic auth", "author=alice", "bob", "", "", "bob"},
+		{"Proxy header", "author=alice", "", "carol", "X-Forwarded-User", "carol"},
+		{"Proxy header missing", "author=alice", "", "", "X-Forwarded-User", ""},
+	}
+
+	for _, test := range tests {
+
+		req := httptest.NewRequest("PUT", "/update?"+test.Query, nil)
+
+		if test.BasicAuth != "" {
+			req.SetBasicAuth(test.BasicAuth, "secret")
+		}
+
+		if test.Header != "" {
+			req.Header.Set(test.AuthorHeader, test.Header)
+		}
+
+		opts := &WriterHandlerOptions{
+			AuthorHeader: test.AuthorHeader,
+		}
+
+		p, err := provenanceFromRequest(req, opts)
+
+		if err != nil {
+			t.Fatalf("%s: failed to derive provenance, %v", test.Name, err)
+		}
+
+		if p.Author != test.Author {
+			t.Errorf("%s: expected author '%s', got '%s'", test.Name, test.Author, p.Author)
+		}
+
+		if len(p.RequestId) != 32 {
+			t.Errorf("%s: expected a random request ID, got '%s'", test.Name, p.RequestId)
+		}
+	}
+}
+
+func TestProvenanceRequestId(t *testing.T) {
+
+	tests := map[string]bool{
+		"abc-123":                true,
+		"req_1.2:3":              true,
+		strings.Repeat("a", 128): true,
+		strings.Repeat("a", 129): false,
+		"abc 123":                false,
+		"<script>":               false,
+		"abcé":                   false,
+	}
+
+	for request_id, valid := range tests {
+
+		req := httptest.NewRequest("PUT", "/update", nil)
+		req.Header.Set("X-Request-Id", request_id)
+
+		p, err := provenanceFromRequest(req, &WriterHandlerOptions{})
+
+		if !valid {
+
+			if err == nil {
+				t.Errorf("Expected request ID '%s' to be refused", request_id)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Expected request ID '%s' to be valid, %v", request_id, err)
+			continue
+		}
+
+		if p.RequestId != request_id {
+			t.Errorf("Expected request ID '%s', got '%s'", request_id, p.RequestId)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/aaronland/go-http-sanitize"
+	"github.com/sfomuseum/go-geojson-geotag"
+	"github.com/sfomuseum/go-www-geotag-whosonfirst/reader"
+	wof_writer "github.com/sfomuseum/go-www-geotag-whosonfirst/writer"
+	_ "log"
+	"net/http"
+)
+
+// ReaderHandler returns an http.Handler that responds to GET requests with the geotag feature,
+// read using rd, for the "id" query parameter. If rd implements the reader.ETagReader interface then
+// the geotag's entity tag, derived from the same read as the geotag, is returned in an "ETag" header.
+func ReaderHandler(rd reader.Reader) (http.Handler, error) {
+
+	fn := func(rsp http.ResponseWriter, req *http.Request) {
+
+		switch req.Method {
+		case "GET":
+			// pass
+		default:
+			http.Error(rsp, "Method not allowed.", http.StatusMethodNotAllowed)
+			return
+		}
+
+		uid, err := sanitize.GetString(req, "id")
+
+		if err != nil {
+			http.Error(rsp, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if uid == "" {
+			http.Error(rsp, "Missing id parameter", http.StatusBadRequest)
+			return
+		}
+
+		ctx := req.Context()
+
+		ctx, err = wof_writer.SetRequestParametersWithContext(ctx, req.URL.Query())
+
+		if err != nil {
+			http.Error(rsp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var geotag_f *geotag.GeotagFeature
+		var etag string
+
+		etr, ok := rd.(reader.ETagReader)
+
+		if ok {
+			geotag_f, etag, err = etr.ReadFeatureWithETag(ctx, uid)
+		} else {
+			geotag_f, err = rd.ReadFeature(ctx, uid)
+		}
+
+		if err != nil {
+			writeError(rsp, err)
+			return
+		}
+
+		if etag != "" {
+			rsp.Header().Set("ETag", etag)
+		}
+
+		rsp.Header().Set("Content-Type", "application/json")
+
+		enc := json.NewEncoder(rsp)
+		err = enc.Encode(geotag_f)
+
+		if err != nil {
+			http.Error(rsp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		return
+	}
+
+	h := http.HandlerFunc(fn)
+	return h, nil
+}
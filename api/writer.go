@@ -0,0 +1,238 @@
+package api
+
+import (
+	"bytes"
+	"github.com/aaronland/go-http-sanitize"
+	"github.com/sfomuseum/go-geojson-geotag"
+	wof_writer "github.com/sfomuseum/go-www-geotag-whosonfirst/writer"
+	"github.com/sfomuseum/go-www-geotag/writer"
+	"github.com/tidwall/gjson"
+	"io/ioutil"
+	_ "log"
+	"net/http"
+)
+
+// WriterHandlerOptions defines optional settings for the handler returned by WriterHandlerWithOptions.
+type WriterHandlerOptions struct {
+	// AuthorHeader is the name of an HTTP header, set by an authenticating proxy, containing the name of
+	// the person making a geotag. If defined the "author" query parameter is ignored.
+	AuthorHeader string
+}
+
+// WriterHandler returns the handler described by WriterHandlerWithOptions using the default options.
+func WriterHandler(wr writer.Writer) (http.Handler, error) {
+	return WriterHandlerWithOptions(wr, &WriterHandlerOptions{})
+}
+
+// WriterHandlerWithOptions is a variant of the go-www-geotag/api.WriterHandler that also assigns the
+// query parameters of each request to the context passed to wr's WriteFeature method. If wr
+// implements the wof_writer.Deleter interface then DELETE requests are also supported. The values of
+// the "If-Match" and "If-None-Match" headers, if present, are assigned to the context as well, along with the
+// provenance of the geotag (see provenanceFromRequest). The entity tag of the geotag after it has been written
+// (or deleted) is returned in the ETag header.
+func WriterHandlerWithOptions(wr writer.Writer, opts *WriterHandlerOptions) (http.Handler, error) {
+
+	fn := func(rsp http.ResponseWriter, req *http.Request) {
+
+		deleter, can_delete := wr.(wof_writer.Deleter)
+
+		switch req.Method {
+		case "PUT":
+			// pass
+		case "DELETE":
+
+			if !can_delete {
+				http.Error(rsp, "Method not allowed.", http.StatusMethodNotAllowed)
+				return
+			}
+
+		default:
+			http.Error(rsp, "Method not allowed.", http.StatusMethodNotAllowed)
+			return
+		}
+
+		defer req.Body.Close()
+
+		uid, err := sanitize.GetString(req, "id")
+
+		if err != nil {
+			http.Error(rsp, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if req.Method == "DELETE" {
+			deleteFeature(rsp, req, deleter, uid, opts)
+			return
+		}
+
+		body, err := ioutil.ReadAll(req.Body)
+
+		if err != nil {
+			http.Error(rsp, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		geotag_f, err := geotag.NewGeotagFeature(body)
+
+		if err != nil {
+			http.Error(rsp, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// the output is buffered so that the entity tag in the report can be returned in the ETag header
+
+		var buf bytes.Buffer
+
+		ctx := req.Context()
+
+		ctx, err = writer.SetIOWriterWithContext(ctx, &buf)
+
+		if err != nil {
+			http.Error(rsp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		ctx, err = wof_writer.SetRequestParametersWithContext(ctx, req.URL.Query())
+
+		if err != nil {
+			http.Error(rsp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		ctx, err = wof_writer.SetIfMatchWithContext(ctx, req.Header.Get("If-Match"))
+
+		if err != nil {
+			http.Error(rsp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		ctx, err = wof_writer.SetIfNoneMatchWithContext(ctx, req.Header.Get("If-None-Match"))
+
+		if err != nil {
+			http.Error(rsp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		provenance, err := provenanceFromRequest(req, opts)
+
+		if err != nil {
+			http.Error(rsp, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rsp.Header().Set("X-Request-Id", provenance.RequestId)
+
+		ctx, err = wof_writer.SetProvenanceWithContext(ctx, provenance)
+
+		if err != nil {
+			http.Error(rsp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		ctx, err = wof_writer.SetFeatureBodyWithContext(ctx, body)
+
+		if err != nil {
+			http.Error(rsp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		err = wr.WriteFeature(ctx, uid, geotag_f)
+
+		if err != nil {
+			writeError(rsp, err)
+			return
+		}
+
+		err = wr.Close(ctx)
+
+		if err != nil {
+			http.Error(rsp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeOutput(rsp, buf.Bytes())
+		return
+	}
+
+	h := http.HandlerFunc(fn)
+	return h, nil
+}
+
+func deleteFeature(rsp http.ResponseWriter, req *http.Request, deleter wof_writer.Deleter, uid string, opts *WriterHandlerOptions) {
+
+	if uid == "" {
+		http.Error(rsp, "Missing id parameter", http.StatusBadRequest)
+		return
+	}
+
+	var buf bytes.Buffer
+
+	ctx := req.Context()
+
+	ctx, err := writer.SetIOWriterWithContext(ctx, &buf)
+
+	if err != nil {
+		http.Error(rsp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ctx, err = wof_writer.SetRequestParametersWithContext(ctx, req.URL.Query())
+
+	if err != nil {
+		http.Error(rsp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ctx, err = wof_writer.SetIfMatchWithContext(ctx, req.Header.Get("If-Match"))
+
+	if err != nil {
+		http.Error(rsp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ctx, err = wof_writer.SetIfNoneMatchWithContext(ctx, req.Header.Get("If-None-Match"))
+
+	if err != nil {
+		http.Error(rsp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	provenance, err := provenanceFromRequest(req, opts)
+
+	if err != nil {
+		http.Error(rsp, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rsp.Header().Set("X-Request-Id", provenance.RequestId)
+
+	ctx, err = wof_writer.SetProvenanceWithContext(ctx, provenance)
+
+	if err != nil {
+		http.Error(rsp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = deleter.DeleteFeature(ctx, uid)
+
+	if err != nil {
+		writeError(rsp, err)
+		return
+	}
+
+	writeOutput(rsp, buf.Bytes())
+}
+
+// writeOutput writes the (JSON) output of a writer to rsp, assigning the "etag" property of the output,
+// if present, to the ETag header.
+func writeOutput(rsp http.ResponseWriter, body []byte) {
+
+	etag_rsp := gjson.GetBytes(body, "etag")
+
+	if etag_rsp.Exists() {
+		rsp.Header().Set("ETag", etag_rsp.String())
+	}
+
+	rsp.Header().Set("Content-Type", "application/json")
+	rsp.Write(body)
+}
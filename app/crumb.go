@@ -0,0 +1,49 @@
+package flags
+
+import (
+	"context"
+	"net/http"
+)
+
+const CRUMB_METHOD_KEY string = "github.com/sfomuseum/go-www-geotag-whosonfirst#crumb_method"
+
+// crumbMethodHandler ensures that DELETE requests are checked by the (go-http-crumb) crumb handler
+// wrapped by next, which only validates crumbs for POST and PUT requests, by passing them through as
+// PUT requests. The original method is restored by restoreMethodHandler.
+func crumbMethodHandler(next http.Handler) http.Handler {
+
+	fn := func(rsp http.ResponseWriter, req *http.Request) {
+
+		if req.Method == "DELETE" {
+
+			ctx := context.WithValue(req.Context(), CRUMB_METHOD_KEY, req.Method)
+
+			req = req.WithContext(ctx)
+			req.Method = "PUT"
+		}
+
+		next.ServeHTTP(rsp, req)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// restoreMethodHandler restores the method of requests that were rewritten by crumbMethodHandler.
+func restoreMethodHandler(next http.Handler) http.Handler {
+
+	fn := func(rsp http.ResponseWriter, req *http.Request) {
+
+		v := req.Context().Value(CRUMB_METHOD_KEY)
+
+		switch v.(type) {
+		case string:
+			req.Method = v.(string)
+		default:
+			// pass
+		}
+
+		next.ServeHTTP(rsp, req)
+	}
+
+	return http.HandlerFunc(fn)
+}
@@ -10,7 +10,15 @@ import (
 func AppendWhosOnFirstFlags(fs *flag.FlagSet) error {
 
 	fs.String("whosonfirst-writer-uri", "", "A valid whosonfirst/go-writer.Writer URI. If present it will be encoded and used to replace the '{whosonfirst_writer}' string in the -writer-uri flag.")
-	fs.String("whosonfirst-reader-uri", "", "A valid whosonfirst/go-reader.Reader URI. If present it will be encoded and used to replace the '{whosonfirst_reader}' string in the -writer-uri flag.")
+	fs.String("whosonfirst-reader-uri", "", "A valid whosonfirst/go-reader.Reader URI. If present it will be encoded and used to replace the '{whosonfirst_reader}' string in the -writer-uri and -reader-uri flags.")
+
+	fs.String("writer-author-header", "", "The name of an HTTP header, set by an authenticating proxy, containing the name of the person making a geotag. If present the (unauthenticated) 'author' query parameter is ignored.")
+
+	fs.Bool("recover-writer-journal", false, "Recover any incomplete writes recorded in the writer's journal directory when the server starts. This should only be enabled if no other process is writing to the same journal directory.")
+
+	fs.Bool("enable-reader", false, "Enable an endpoint for reading existing geotags using a go-www-geotag-whosonfirst/reader.Reader instance.")
+	fs.String("path-reader", "/geotag", "A relative path for reading existing geotags.")
+	fs.String("reader-uri", "whosonfirst://?reader={whosonfirst_reader}", "A valid go-www-geotag-whosonfirst/reader.Reader URI for creating a reader.Reader instance.")
 
 	return nil
 }
@@ -29,7 +37,7 @@ func AssignWhosOnFirstFlags(fs *flag.FlagSet) error {
 		return err
 	}
 
-	if wof_writer != "" || wof_writer != "" {
+	if wof_writer != "" || wof_reader != "" {
 
 		writer_uri, err := lookup.StringVar(fs, "writer-uri")
 
@@ -50,5 +58,19 @@ func AssignWhosOnFirstFlags(fs *flag.FlagSet) error {
 		fs.Set("writer-uri", writer_uri)
 	}
 
+	if wof_reader != "" {
+
+		reader_uri, err := lookup.StringVar(fs, "reader-uri")
+
+		if err != nil {
+			return err
+		}
+
+		enc_wof_reader := url.QueryEscape(wof_reader)
+		reader_uri = strings.Replace(reader_uri, "{whosonfirst_reader}", enc_wof_reader, 1)
+
+		fs.Set("reader-uri", reader_uri)
+	}
+
 	return nil
 }
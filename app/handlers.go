@@ -0,0 +1,199 @@
+package flags
+
+import (
+	"context"
+	"flag"
+	"github.com/rs/cors"
+	"github.com/sfomuseum/go-flags/lookup"
+	"github.com/sfomuseum/go-www-geotag-whosonfirst/api"
+	"github.com/sfomuseum/go-www-geotag-whosonfirst/reader"
+	wof_writer "github.com/sfomuseum/go-www-geotag-whosonfirst/writer"
+	"github.com/sfomuseum/go-www-geotag/app"
+	"github.com/sfomuseum/go-www-geotag/writer"
+	"net/http"
+	"strings"
+)
+
+// AppendWriterHandlerIfEnabled is a variant of the go-www-geotag/app.AppendWriterHandlerIfEnabled
+// method that uses the api.WriterHandler defined in this package.
+func AppendWriterHandlerIfEnabled(ctx context.Context, fs *flag.FlagSet, mux *http.ServeMux) error {
+
+	enable_writer, err := lookup.BoolVar(fs, "enable-writer")
+
+	if err != nil {
+		return err
+	}
+
+	if !enable_writer {
+		return nil
+	}
+
+	return AppendWriterHandler(ctx, fs, mux)
+}
+
+func AppendWriterHandler(ctx context.Context, fs *flag.FlagSet, mux *http.ServeMux) error {
+
+	path, err := lookup.StringVar(fs, "path-writer")
+
+	if err != nil {
+		return err
+	}
+
+	handler, err := NewWriterHandler(ctx, fs)
+
+	if err != nil {
+		return err
+	}
+
+	mux.Handle(path, handler)
+	return nil
+}
+
+func NewWriterHandler(ctx context.Context, fs *flag.FlagSet) (http.Handler, error) {
+
+	writer_uri, err := lookup.StringVar(fs, "writer-uri")
+
+	if err != nil {
+		return nil, err
+	}
+
+	disable_writer_crumb, err := lookup.BoolVar(fs, "disable-writer-crumb")
+
+	if err != nil {
+		return nil, err
+	}
+
+	enable_writer_cors, err := lookup.BoolVar(fs, "enable-writer-cors")
+
+	if err != nil {
+		return nil, err
+	}
+
+	allowed_origins_str, err := lookup.StringVar(fs, "writer-cors-allowed-origins")
+
+	if err != nil {
+		return nil, err
+	}
+
+	recover_journal, err := lookup.BoolVar(fs, "recover-writer-journal")
+
+	if err != nil {
+		return nil, err
+	}
+
+	author_header, err := lookup.StringVar(fs, "writer-author-header")
+
+	if err != nil {
+		return nil, err
+	}
+
+	wr, err := writer.NewWriter(ctx, writer_uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// recover any writes that were interrupted the last time the server was running. this is opt-in
+	// because the journal may contain the (in-progress) writes of another server sharing the journal
+
+	rc, ok := wr.(wof_writer.Recoverer)
+
+	if ok && recover_journal {
+
+		err = rc.Recover(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	handler_opts := &api.WriterHandlerOptions{
+		AuthorHeader: author_header,
+	}
+
+	handler, err := api.WriterHandlerWithOptions(wr, handler_opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !disable_writer_crumb {
+
+		// crumbs are validated for DELETE requests as well as PUT requests
+
+		handler, err = app.AppendCrumbHandler(ctx, fs, restoreMethodHandler(handler))
+
+		if err != nil {
+			return nil, err
+		}
+
+		handler = crumbMethodHandler(handler)
+	}
+
+	if enable_writer_cors {
+
+		allowed_origins := strings.Split(allowed_origins_str, ",")
+
+		cors_handler := cors.New(cors.Options{
+			AllowedOrigins: allowed_origins,
+			AllowedMethods: []string{"PUT", "DELETE"},
+			AllowedHeaders: []string{"Origin", "Accept", "Content-Type", "X-Requested-With", "If-Match", "If-None-Match", "X-Request-Id", "Authorization"},
+			ExposedHeaders: []string{"ETag", "X-Request-Id"},
+		})
+
+		handler = cors_handler.Handler(handler)
+	}
+
+	return handler, nil
+}
+
+// AppendReaderHandlerIfEnabled appends an api.ReaderHandler to mux if the -enable-reader flag is true.
+func AppendReaderHandlerIfEnabled(ctx context.Context, fs *flag.FlagSet, mux *http.ServeMux) error {
+
+	enable_reader, err := lookup.BoolVar(fs, "enable-reader")
+
+	if err != nil {
+		return err
+	}
+
+	if !enable_reader {
+		return nil
+	}
+
+	return AppendReaderHandler(ctx, fs, mux)
+}
+
+func AppendReaderHandler(ctx context.Context, fs *flag.FlagSet, mux *http.ServeMux) error {
+
+	path, err := lookup.StringVar(fs, "path-reader")
+
+	if err != nil {
+		return err
+	}
+
+	handler, err := NewReaderHandler(ctx, fs)
+
+	if err != nil {
+		return err
+	}
+
+	mux.Handle(path, handler)
+	return nil
+}
+
+func NewReaderHandler(ctx context.Context, fs *flag.FlagSet) (http.Handler, error) {
+
+	reader_uri, err := lookup.StringVar(fs, "reader-uri")
+
+	if err != nil {
+		return nil, err
+	}
+
+	rd, err := reader.NewReader(ctx, reader_uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return api.ReaderHandler(rd)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	_ "github.com/sfomuseum/go-www-geotag-whosonfirst/writer"
+)
+
+import (
+	"context"
+	"github.com/sfomuseum/go-flags/flagset"
+	"github.com/sfomuseum/go-flags/lookup"
+	wof_app "github.com/sfomuseum/go-www-geotag-whosonfirst/app"
+	wof_writer "github.com/sfomuseum/go-www-geotag-whosonfirst/writer"
+	"github.com/sfomuseum/go-www-geotag/writer"
+	"log"
+	"net/url"
+)
+
+func main() {
+
+	fs := flagset.NewFlagSet("recover")
+
+	fs.String("writer-uri", "", "A valid go-www-geotag/writer.Writer URI. For the whosonfirst:// writer this should include the same 'journal' parameter used by the server.")
+
+	fs.Bool("force", false, "Recover incomplete writes even if the writer URI does not set 'lockfile=1'. Only use this when no other process is writing to the same records.")
+
+	err := wof_app.AppendWhosOnFirstFlags(fs)
+
+	if err != nil {
+		log.Fatalf("Failed to append Who's On First flags, %v", err)
+	}
+
+	flagset.Parse(fs)
+
+	err = flagset.SetFlagsFromEnvVars(fs, "GEOTAG")
+
+	if err != nil {
+		log.Fatalf("Failed to set flags from env vars, %v", err)
+	}
+
+	err = wof_app.AssignWhosOnFirstFlags(fs)
+
+	if err != nil {
+		log.Fatalf("Failed to assign Who's On First flags, %v", err)
+	}
+
+	writer_uri, err := lookup.StringVar(fs, "writer-uri")
+
+	if err != nil {
+		log.Fatalf("Failed to lookup writer-uri flag, %v", err)
+	}
+
+	force, err := lookup.BoolVar(fs, "force")
+
+	if err != nil {
+		log.Fatalf("Failed to lookup force flag, %v", err)
+	}
+
+	// per-ID locks only apply across processes if the writer uses lockfiles, otherwise
+	// a write still in progress in a running server looks the same as an abandoned one
+
+	u, err := url.Parse(writer_uri)
+
+	if err != nil {
+		log.Fatalf("Failed to parse writer URI, %v", err)
+	}
+
+	if u.Query().Get("lockfile") != "1" && !force {
+		log.Fatalf("Refusing to recover incomplete writes because the writer URI does not set 'lockfile=1' so writes in progress in other processes can not be detected. Stop any servers writing to the same records and run again with -force.")
+	}
+
+	ctx := context.Background()
+
+	wr, err := writer.NewWriter(ctx, writer_uri)
+
+	if err != nil {
+		log.Fatalf("Failed to create writer, %v", err)
+	}
+
+	rc, ok := wr.(wof_writer.Recoverer)
+
+	if !ok {
+		log.Fatalf("Writer does not support recovering incomplete writes")
+	}
+
+	err = rc.Recover(ctx)
+
+	if err != nil {
+		log.Fatalf("Failed to recover incomplete writes, %v", err)
+	}
+}
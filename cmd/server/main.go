@@ -63,12 +63,18 @@ func main() {
 		log.Fatalf("Failed to append proxy tiles handler, %v", err)
 	}
 
-	err = app.AppendWriterHandlerIfEnabled(ctx, fs, mux)
+	err = wof_app.AppendWriterHandlerIfEnabled(ctx, fs, mux)
 
 	if err != nil {
 		log.Fatalf("Failed to append writer handler, %v", err)
 	}
 
+	err = wof_app.AppendReaderHandlerIfEnabled(ctx, fs, mux)
+
+	if err != nil {
+		log.Fatalf("Failed to append reader handler, %v", err)
+	}
+
 	s, err := app.NewServer(ctx, fs)
 
 	if err != nil {
@@ -0,0 +1,116 @@
+package geo
+
+import (
+	"github.com/paulmach/orb"
+	"math"
+)
+
+// Area returns the area (in square meters) of g on the WGS84 ellipsoid. Latitudes are converted to authalic
+// latitudes and the area is computed on a sphere with the authalic radius of the ellipsoid, which preserves
+// areas. Points and lines have no area.
+func Area(g orb.Geometry) float64 {
+
+	switch g := g.(type) {
+	case orb.Polygon:
+		return polygonArea(g)
+	case orb.MultiPolygon:
+
+		area := 0.0
+
+		for _, p := range g {
+			area += polygonArea(p)
+		}
+
+		return area
+
+	case orb.Collection:
+
+		area := 0.0
+
+		for _, c := range g {
+			area += Area(c)
+		}
+
+		return area
+
+	default:
+		return 0.0
+	}
+}
+
+func polygonArea(p orb.Polygon) float64 {
+
+	area := 0.0
+
+	for i, r := range p {
+
+		a := math.Abs(ringArea(r))
+
+		if i == 0 {
+			area += a
+		} else {
+			area -= a
+		}
+	}
+
+	return area
+}
+
+// ringArea returns the signed area of r using the Chamberlain and Duquette spherical excess formula
+// on the authalic sphere.
+func ringArea(r orb.Ring) float64 {
+
+	count := len(r)
+
+	if count < 3 {
+		return 0.0
+	}
+
+	if r[0] == r[count-1] {
+		count -= 1
+	}
+
+	radius := authalicRadius()
+	area := 0.0
+
+	for i := 0; i < count; i++ {
+
+		prev := r[(i+count-1)%count]
+		next := r[(i+1)%count]
+
+		area += toRadians(next.X()-prev.X()) * math.Sin(authalicLatitude(toRadians(r[i].Y())))
+	}
+
+	return -area * radius * radius / 2.0
+}
+
+func eccentricity() float64 {
+	return math.Sqrt(WGS84_F * (2.0 - WGS84_F))
+}
+
+func q(phi float64) float64 {
+
+	e := eccentricity()
+	sin_phi := math.Sin(phi)
+
+	return (1.0 - e*e) * (sin_phi/(1.0-e*e*sin_phi*sin_phi) - (1.0/(2.0*e))*math.Log((1.0-e*sin_phi)/(1.0+e*sin_phi)))
+}
+
+// authalicRadius returns the radius of the sphere with the same surface area as the WGS84 ellipsoid.
+func authalicRadius() float64 {
+	return WGS84_A * math.Sqrt(q(math.Pi/2.0)/2.0)
+}
+
+// authalicLatitude returns the authalic latitude (in radians) for the geodetic latitude phi (in radians).
+func authalicLatitude(phi float64) float64 {
+
+	ratio := q(phi) / q(math.Pi/2.0)
+
+	if ratio > 1.0 {
+		ratio = 1.0
+	} else if ratio < -1.0 {
+		ratio = -1.0
+	}
+
+	return math.Asin(ratio)
+}
@@ -0,0 +1,53 @@
+package geo
+
+import (
+	"github.com/paulmach/orb"
+	"math"
+	"testing"
+)
+
+// wgs84_area is the surface area of the WGS84 ellipsoid in square meters.
+const wgs84_area float64 = 510065621718491.4
+
+func TestArea(t *testing.T) {
+
+	northern_hemisphere := orb.Ring{
+		{-180.0, 0.0}, {-90.0, 0.0}, {0.0, 0.0}, {90.0, 0.0}, {180.0, 0.0},
+		{180.0, 90.0}, {-180.0, 90.0}, {-180.0, 0.0},
+	}
+
+	// the same ring wound clockwise
+
+	southern_ring := orb.Ring{
+		{-180.0, 0.0}, {-180.0, -90.0}, {180.0, -90.0}, {180.0, 0.0},
+		{90.0, 0.0}, {0.0, 0.0}, {-90.0, 0.0}, {-180.0, 0.0},
+	}
+
+	quarter := orb.Ring{
+		{-180.0, 0.0}, {-90.0, 0.0}, {0.0, 0.0}, {0.0, 90.0}, {-180.0, 90.0}, {-180.0, 0.0},
+	}
+
+	tests := []struct {
+		Name     string
+		Geometry orb.Geometry
+		Area     float64
+	}{
+		{"Northern hemisphere", orb.Polygon{northern_hemisphere}, wgs84_area / 2.0},
+		{"Southern hemisphere (clockwise)", orb.Polygon{southern_ring}, wgs84_area / 2.0},
+		{"Northern hemisphere with a hole", orb.Polygon{northern_hemisphere, quarter}, wgs84_area / 4.0},
+		{"Both hemispheres", orb.MultiPolygon{{northern_hemisphere}, {southern_ring}}, wgs84_area},
+		{"Point", orb.Point{0.0, 0.0}, 0.0},
+		{"LineString", orb.LineString{{0.0, 0.0}, {1.0, 1.0}}, 0.0},
+	}
+
+	for _, test := range tests {
+
+		area := Area(test.Geometry)
+
+		// allow for floating point errors when summing the rings
+
+		if math.Abs(area-test.Area) > test.Area*1e-9 {
+			t.Errorf("%s: expected area %f, got %f", test.Name, test.Area, area)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package geo
+
+import (
+	"errors"
+	"github.com/paulmach/orb"
+)
+
+// Sector returns a polygon describing the circular sector centered on camera, spanning angle
+// degrees around bearing, whose far edge is a geodesic arc (approximated by segments segments)
+// at distance meters from camera. If angle is 360 (or more) then a circle is returned. The
+// polygon's exterior ring is wound counter-clockwise (right-hand rule).
+func Sector(camera orb.Point, bearing float64, angle float64, distance float64, segments int) (orb.Polygon, error) {
+
+	if segments < 1 {
+		return nil, errors.New("Invalid number of segments")
+	}
+
+	if angle >= 360.0 {
+
+		ring := make(orb.Ring, 0)
+
+		for i := 0; i < segments; i++ {
+
+			b := bearing - (360.0 * float64(i) / float64(segments))
+
+			pt, err := Direct(camera, b, distance)
+
+			if err != nil {
+				return nil, err
+			}
+
+			ring = append(ring, pt)
+		}
+
+		ring = append(ring, ring[0])
+		return orb.Polygon{ring}, nil
+	}
+
+	arc, err := Arc(camera, bearing, angle, distance, segments)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ring := orb.Ring{camera}
+	ring = append(ring, arc...)
+	ring = append(ring, camera)
+
+	return orb.Polygon{ring}, nil
+}
+
+// Arc returns segments + 1 points along the geodesic arc at distance meters from camera spanning
+// angle degrees around bearing, ordered from bearing + angle/2 to bearing - angle/2 (counter-clockwise).
+func Arc(camera orb.Point, bearing float64, angle float64, distance float64, segments int) ([]orb.Point, error) {
+
+	if segments < 1 {
+		return nil, errors.New("Invalid number of segments")
+	}
+
+	start := bearing + (angle / 2.0)
+	step := angle / float64(segments)
+
+	points := make([]orb.Point, segments+1)
+
+	for i := 0; i <= segments; i++ {
+
+		pt, err := Direct(camera, start-(step*float64(i)), distance)
+
+		if err != nil {
+			return nil, err
+		}
+
+		points[i] = pt
+	}
+
+	return points, nil
+}
+
+// AnnularSector returns a polygon describing the area between near and far meters from camera
+// spanning angle degrees around bearing. Both the near and far edges are geodesic arcs approximated
+// by segments segments. If near is 0 then the result is the same as Sector. If angle is 360 (or
+// more) then an annulus (a circle with a circular hole) is returned.
+func AnnularSector(camera orb.Point, bearing float64, angle float64, near float64, far float64, segments int) (orb.Polygon, error) {
+
+	if near <= 0.0 {
+		return Sector(camera, bearing, angle, far, segments)
+	}
+
+	if near >= far {
+		return nil, errors.New("Near distance must be less than far distance")
+	}
+
+	if angle >= 360.0 {
+
+		outer, err := Sector(camera, bearing, angle, far, segments)
+
+		if err != nil {
+			return nil, err
+		}
+
+		inner, err := Sector(camera, bearing, angle, near, segments)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// interior rings are wound clockwise
+
+		hole := inner[0]
+		hole.Reverse()
+
+		return orb.Polygon{outer[0], hole}, nil
+	}
+
+	far_arc, err := Arc(camera, bearing, angle, far, segments)
+
+	if err != nil {
+		return nil, err
+	}
+
+	near_arc, err := Arc(camera, bearing, angle, near, segments)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ring := orb.Ring(far_arc)
+
+	for i := len(near_arc) - 1; i >= 0; i-- {
+		ring = append(ring, near_arc[i])
+	}
+
+	ring = append(ring, far_arc[0])
+
+	return orb.Polygon{ring}, nil
+}
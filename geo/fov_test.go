@@ -0,0 +1,107 @@
+package geo
+
+import (
+	"github.com/paulmach/orb"
+	"testing"
+)
+
+func TestFieldOfViewOrientation(t *testing.T) {
+
+	camera := orb.Point{-122.36640930175783, 37.61888804488137}
+
+	tests := []struct {
+		Name    string
+		Bearing float64
+		Angle   float64
+		Near    float64
+	}{
+		{"Sector", -106.5, 20.0, 0.0},
+		{"Sector (north)", 0.0, 45.0, 0.0},
+		{"Sector (wide)", 90.0, 270.0, 0.0},
+		{"Circle", 0.0, 360.0, 0.0},
+		{"Annular sector", -106.5, 20.0, 1000.0},
+		{"Annular sector (south)", 180.0, 90.0, 1000.0},
+		{"Annulus", 0.0, 360.0, 1000.0},
+	}
+
+	for _, test := range tests {
+
+		poly, err := AnnularSector(camera, test.Bearing, test.Angle, test.Near, 4000.0, 16)
+
+		if err != nil {
+			t.Fatalf("%s: failed to create field of view, %v", test.Name, err)
+		}
+
+		for i, ring := range poly {
+
+			if !ring.Closed() {
+				t.Errorf("%s: ring %d is not closed", test.Name, i)
+			}
+
+			expected := orb.CCW
+
+			if i > 0 {
+				expected = orb.CW
+			}
+
+			if ring.Orientation() != expected {
+				t.Errorf("%s: ring %d has the wrong orientation", test.Name, i)
+			}
+		}
+
+		if test.Angle >= 360.0 && test.Near > 0.0 && len(poly) != 2 {
+			t.Errorf("%s: expected an interior ring", test.Name)
+		}
+	}
+}
+
+func TestArc(t *testing.T) {
+
+	camera := orb.Point{0.0, 0.0}
+
+	arc, err := Arc(camera, 90.0, 90.0, 1000.0, 4)
+
+	if err != nil {
+		t.Fatalf("Failed to create arc, %v", err)
+	}
+
+	if len(arc) != 5 {
+		t.Fatalf("Expected 5 points, got %d", len(arc))
+	}
+
+	// points are ordered from bearing + angle/2 to bearing - angle/2
+
+	expected := []float64{135.0, 112.5, 90.0, 67.5, 45.0}
+
+	for i, pt := range arc {
+
+		distance, bearing, _, err := Inverse(camera, pt)
+
+		if err != nil {
+			t.Fatalf("Failed to derive inverse, %v", err)
+		}
+
+		if !sameBearing(bearing, expected[i]) {
+			t.Errorf("Expected point %d to have bearing %f, got %f", i, expected[i], bearing)
+		}
+
+		if distance < 1000.0-distance_tolerance || distance > 1000.0+distance_tolerance {
+			t.Errorf("Expected point %d to be 1000m from the camera, got %f", i, distance)
+		}
+	}
+}
+
+func TestAnnularSectorDistances(t *testing.T) {
+
+	_, err := AnnularSector(orb.Point{0.0, 0.0}, 0.0, 45.0, 4000.0, 1000.0, 16)
+
+	if err == nil {
+		t.Errorf("Expected a near distance greater than the far distance to fail")
+	}
+
+	_, err = Sector(orb.Point{0.0, 0.0}, 0.0, 45.0, 1000.0, 0)
+
+	if err == nil {
+		t.Errorf("Expected 0 segments to fail")
+	}
+}
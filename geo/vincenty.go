@@ -0,0 +1,198 @@
+// package geo provides methods for geodesic calculations on the WGS84 ellipsoid.
+package geo
+
+import (
+	"errors"
+	"github.com/paulmach/orb"
+	"math"
+)
+
+// WGS84 ellipsoid parameters.
+const WGS84_A float64 = 6378137.0
+const WGS84_F float64 = 1.0 / 298.257223563
+const WGS84_B float64 = (1.0 - WGS84_F) * WGS84_A
+
+const max_iterations int = 200
+const epsilon float64 = 1e-12
+
+// Inverse returns the geodesic distance (in meters) and the initial and final bearings (in
+// degrees, clockwise from north) between the points from and to using Vincenty's inverse formula.
+// Points are [longitude, latitude] pairs.
+func Inverse(from orb.Point, to orb.Point) (float64, float64, float64, error) {
+
+	f := WGS84_F
+	a := WGS84_A
+	b := WGS84_B
+
+	L := toRadians(to.X() - from.X())
+
+	U1 := math.Atan((1.0 - f) * math.Tan(toRadians(from.Y())))
+	U2 := math.Atan((1.0 - f) * math.Tan(toRadians(to.Y())))
+
+	sinU1, cosU1 := math.Sincos(U1)
+	sinU2, cosU2 := math.Sincos(U2)
+
+	lambda := L
+
+	var sinLambda, cosLambda float64
+	var sinSigma, cosSigma, sigma float64
+	var cos2Alpha, cos2SigmaM float64
+
+	converged := false
+
+	for i := 0; i < max_iterations; i++ {
+
+		sinLambda, cosLambda = math.Sincos(lambda)
+
+		t1 := cosU2 * sinLambda
+		t2 := cosU1*sinU2 - sinU1*cosU2*cosLambda
+
+		sinSigma = math.Sqrt(t1*t1 + t2*t2)
+
+		if sinSigma == 0.0 {
+			return 0.0, 0.0, 0.0, nil // coincident points
+		}
+
+		cosSigma = sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma = math.Atan2(sinSigma, cosSigma)
+
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cos2Alpha = 1.0 - sinAlpha*sinAlpha
+
+		cos2SigmaM = 0.0 // equatorial line
+
+		if cos2Alpha != 0.0 {
+			cos2SigmaM = cosSigma - 2.0*sinU1*sinU2/cos2Alpha
+		}
+
+		C := f / 16.0 * cos2Alpha * (4.0 + f*(4.0-3.0*cos2Alpha))
+
+		prev := lambda
+		lambda = L + (1.0-C)*f*sinAlpha*(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1.0+2.0*cos2SigmaM*cos2SigmaM)))
+
+		if math.Abs(lambda-prev) < epsilon {
+			converged = true
+			break
+		}
+	}
+
+	if !converged {
+		return 0.0, 0.0, 0.0, errors.New("Inverse formula failed to converge")
+	}
+
+	uSq := cos2Alpha * (a*a - b*b) / (b * b)
+	A := 1.0 + uSq/16384.0*(4096.0+uSq*(-768.0+uSq*(320.0-175.0*uSq)))
+	B := uSq / 1024.0 * (256.0 + uSq*(-128.0+uSq*(74.0-47.0*uSq)))
+
+	deltaSigma := B * sinSigma * (cos2SigmaM + B/4.0*(cosSigma*(-1.0+2.0*cos2SigmaM*cos2SigmaM)-B/6.0*cos2SigmaM*(-3.0+4.0*sinSigma*sinSigma)*(-3.0+4.0*cos2SigmaM*cos2SigmaM)))
+
+	distance := b * A * (sigma - deltaSigma)
+
+	initial := math.Atan2(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)
+	final := math.Atan2(cosU1*sinLambda, -sinU1*cosU2+cosU1*sinU2*cosLambda)
+
+	return distance, NormalizeBearing(toDegrees(initial)), NormalizeBearing(toDegrees(final)), nil
+}
+
+// Direct returns the point reached by travelling distance meters from the point from along the
+// geodesic with the initial bearing (in degrees, clockwise from north) using Vincenty's direct
+// formula.
+func Direct(from orb.Point, bearing float64, distance float64) (orb.Point, error) {
+
+	f := WGS84_F
+	a := WGS84_A
+	b := WGS84_B
+
+	sinAlpha1, cosAlpha1 := math.Sincos(toRadians(bearing))
+
+	tanU1 := (1.0 - f) * math.Tan(toRadians(from.Y()))
+	cosU1 := 1.0 / math.Sqrt(1.0+tanU1*tanU1)
+	sinU1 := tanU1 * cosU1
+
+	sigma1 := math.Atan2(tanU1, cosAlpha1)
+	sinAlpha := cosU1 * sinAlpha1
+	cos2Alpha := 1.0 - sinAlpha*sinAlpha
+
+	uSq := cos2Alpha * (a*a - b*b) / (b * b)
+	A := 1.0 + uSq/16384.0*(4096.0+uSq*(-768.0+uSq*(320.0-175.0*uSq)))
+	B := uSq / 1024.0 * (256.0 + uSq*(-128.0+uSq*(74.0-47.0*uSq)))
+
+	sigma := distance / (b * A)
+
+	var sinSigma, cosSigma, cos2SigmaM float64
+
+	converged := false
+
+	for i := 0; i < max_iterations; i++ {
+
+		cos2SigmaM = math.Cos(2.0*sigma1 + sigma)
+		sinSigma, cosSigma = math.Sincos(sigma)
+
+		deltaSigma := B * sinSigma * (cos2SigmaM + B/4.0*(cosSigma*(-1.0+2.0*cos2SigmaM*cos2SigmaM)-B/6.0*cos2SigmaM*(-3.0+4.0*sinSigma*sinSigma)*(-3.0+4.0*cos2SigmaM*cos2SigmaM)))
+
+		prev := sigma
+		sigma = distance/(b*A) + deltaSigma
+
+		if math.Abs(sigma-prev) < epsilon {
+			converged = true
+			break
+		}
+	}
+
+	if !converged {
+		return orb.Point{}, errors.New("Direct formula failed to converge")
+	}
+
+	sinSigma, cosSigma = math.Sincos(sigma)
+	cos2SigmaM = math.Cos(2.0*sigma1 + sigma)
+
+	x := sinU1*sinSigma - cosU1*cosSigma*cosAlpha1
+
+	lat := math.Atan2(sinU1*cosSigma+cosU1*sinSigma*cosAlpha1, (1.0-f)*math.Sqrt(sinAlpha*sinAlpha+x*x))
+	lambda := math.Atan2(sinSigma*sinAlpha1, cosU1*cosSigma-sinU1*sinSigma*cosAlpha1)
+
+	C := f / 16.0 * cos2Alpha * (4.0 + f*(4.0-3.0*cos2Alpha))
+	L := lambda - (1.0-C)*f*sinAlpha*(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1.0+2.0*cos2SigmaM*cos2SigmaM)))
+
+	lon := normalizeLongitude(from.X() + toDegrees(L))
+
+	return orb.Point{lon, toDegrees(lat)}, nil
+}
+
+// Midpoint returns the point halfway along the geodesic between the points from and to.
+func Midpoint(from orb.Point, to orb.Point) (orb.Point, error) {
+
+	distance, bearing, _, err := Inverse(from, to)
+
+	if err != nil {
+		return orb.Point{}, err
+	}
+
+	return Direct(from, bearing, distance/2.0)
+}
+
+// NormalizeBearing returns bearing (in degrees) in the range (-180, 180].
+func NormalizeBearing(bearing float64) float64 {
+
+	bearing = math.Mod(bearing, 360.0)
+
+	if bearing > 180.0 {
+		bearing -= 360.0
+	} else if bearing <= -180.0 {
+		bearing += 360.0
+	}
+
+	return bearing
+}
+
+func normalizeLongitude(lon float64) float64 {
+	return NormalizeBearing(lon)
+}
+
+func toRadians(d float64) float64 {
+	return d * math.Pi / 180.0
+}
+
+func toDegrees(r float64) float64 {
+	return r * 180.0 / math.Pi
+}
@@ -0,0 +1,162 @@
+package geo
+
+import (
+	"github.com/paulmach/orb"
+	"math"
+	"testing"
+)
+
+// dms converts degrees, minutes and seconds to decimal degrees.
+func dms(d float64, m float64, s float64) float64 {
+
+	v := math.Abs(d) + m/60.0 + s/3600.0
+
+	if d < 0 {
+		v = -v
+	}
+
+	return v
+}
+
+type geodesicTest struct {
+	Name         string
+	From         orb.Point
+	To           orb.Point
+	Distance     float64
+	Bearing      float64
+	FinalBearing float64
+}
+
+// geodesicTests are published reference values for the WGS84 ellipsoid. Flinders Peak to Buninyong is the
+// worked example from Geoscience Australia (after Vincenty, 1975), the equatorial degree is a * π / 180 and
+// the meridian quadrant is the distance from the equator to the pole.
+var geodesicTests = []geodesicTest{
+	{
+		Name:         "Flinders Peak to Buninyong",
+		From:         orb.Point{dms(144, 25, 29.52440), dms(-37, 57, 3.72030)},
+		To:           orb.Point{dms(143, 55, 35.38390), dms(-37, 39, 10.15610)},
+		Distance:     54972.271,
+		Bearing:      dms(306, 52, 5.37),
+		FinalBearing: dms(127, 10, 25.07) + 180.0,
+	},
+	{
+		Name:         "One degree along the equator",
+		From:         orb.Point{0.0, 0.0},
+		To:           orb.Point{1.0, 0.0},
+		Distance:     111319.491,
+		Bearing:      90.0,
+		FinalBearing: 90.0,
+	},
+	{
+		Name:         "Meridian quadrant",
+		From:         orb.Point{0.0, 0.0},
+		To:           orb.Point{0.0, 90.0},
+		Distance:     10001965.729,
+		Bearing:      0.0,
+		FinalBearing: 0.0,
+	},
+}
+
+// distance_tolerance is 1mm and bearing_tolerance is 0.01 seconds of arc, the precision of the reference values.
+const distance_tolerance float64 = 0.001
+const bearing_tolerance float64 = 0.01 / 3600.0
+
+func sameBearing(a float64, b float64) bool {
+	return math.Abs(NormalizeBearing(a-b)) <= bearing_tolerance
+}
+
+func TestInverse(t *testing.T) {
+
+	for _, test := range geodesicTests {
+
+		distance, bearing, final_bearing, err := Inverse(test.From, test.To)
+
+		if err != nil {
+			t.Fatalf("%s: failed to derive inverse, %v", test.Name, err)
+		}
+
+		if math.Abs(distance-test.Distance) > distance_tolerance {
+			t.Errorf("%s: expected distance %f, got %f", test.Name, test.Distance, distance)
+		}
+
+		if !sameBearing(bearing, test.Bearing) {
+			t.Errorf("%s: expected bearing %f, got %f", test.Name, test.Bearing, bearing)
+		}
+
+		if !sameBearing(final_bearing, test.FinalBearing) {
+			t.Errorf("%s: expected final bearing %f, got %f", test.Name, test.FinalBearing, final_bearing)
+		}
+	}
+}
+
+func TestDirect(t *testing.T) {
+
+	// 1e-8 degrees is roughly 1mm
+
+	coord_tolerance := 1e-8
+
+	for _, test := range geodesicTests {
+
+		pt, err := Direct(test.From, test.Bearing, test.Distance)
+
+		if err != nil {
+			t.Fatalf("%s: failed to derive direct, %v", test.Name, err)
+		}
+
+		if math.Abs(pt.Y()-test.To.Y()) > coord_tolerance {
+			t.Errorf("%s: expected latitude %f, got %f", test.Name, test.To.Y(), pt.Y())
+		}
+
+		// longitudes are meaningless at the poles
+
+		if math.Abs(test.To.Y()) < 90.0 && math.Abs(pt.X()-test.To.X()) > coord_tolerance {
+			t.Errorf("%s: expected longitude %f, got %f", test.Name, test.To.X(), pt.X())
+		}
+	}
+}
+
+func TestMidpoint(t *testing.T) {
+
+	tests := []struct {
+		From     orb.Point
+		To       orb.Point
+		Midpoint orb.Point
+	}{
+		{orb.Point{0.0, 0.0}, orb.Point{2.0, 0.0}, orb.Point{1.0, 0.0}},
+		{orb.Point{10.0, -5.0}, orb.Point{10.0, 5.0}, orb.Point{10.0, 0.0}},
+	}
+
+	for _, test := range tests {
+
+		pt, err := Midpoint(test.From, test.To)
+
+		if err != nil {
+			t.Fatalf("Failed to derive midpoint of %v and %v, %v", test.From, test.To, err)
+		}
+
+		if math.Abs(pt.X()-test.Midpoint.X()) > 1e-9 || math.Abs(pt.Y()-test.Midpoint.Y()) > 1e-9 {
+			t.Errorf("Expected midpoint of %v and %v to be %v, got %v", test.From, test.To, test.Midpoint, pt)
+		}
+	}
+}
+
+func TestNormalizeBearing(t *testing.T) {
+
+	tests := map[float64]float64{
+		0.0:    0.0,
+		180.0:  180.0,
+		-180.0: 180.0,
+		270.0:  -90.0,
+		-270.0: 90.0,
+		720.0:  0.0,
+	}
+
+	for bearing, expected := range tests {
+
+		v := NormalizeBearing(bearing)
+
+		if v != expected {
+			t.Errorf("Expected %f to be normalized to %f, got %f", bearing, expected, v)
+		}
+	}
+}
@@ -3,6 +3,10 @@ module github.com/sfomuseum/go-www-geotag-whosonfirst
 go 1.12
 
 require (
+	github.com/aaronland/go-http-sanitize v0.0.4
+	github.com/aaronland/go-roster v0.0.2
+	github.com/paulmach/orb v0.1.6
+	github.com/rs/cors v1.7.0
 	github.com/sfomuseum/go-flags v0.2.1
 	github.com/sfomuseum/go-geojson-geotag v0.0.3
 	github.com/sfomuseum/go-www-geotag v0.0.17
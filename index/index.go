@@ -0,0 +1,417 @@
+// package index provides a simple in-memory spatial index of Who's On First records.
+package index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/paulmach/orb/planar"
+	"github.com/tidwall/gjson"
+	"github.com/whosonfirst/go-reader"
+	wof_uri "github.com/whosonfirst/go-whosonfirst-uri"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Place is a Who's On First record with a polygon (or multipolygon) geometry.
+type Place struct {
+	Id        int64
+	Name      string
+	Placetype string
+	ParentId  int64
+	Hierarchy []map[string]int64
+	Geometry  orb.Geometry
+	Bound     orb.Bound
+	// Area is the planar area (in square degrees) of the place's geometry. It is used to rank places by specificity.
+	Area float64
+}
+
+// Contains reports whether pt is inside p's geometry.
+func (p *Place) Contains(pt orb.Point) bool {
+
+	if !p.Bound.Contains(pt) {
+		return false
+	}
+
+	switch g := p.Geometry.(type) {
+	case orb.Polygon:
+		return planar.PolygonContains(g, pt)
+	case orb.MultiPolygon:
+		return planar.MultiPolygonContains(g, pt)
+	default:
+		return false
+	}
+}
+
+// SpatialIndex is a simple in-memory spatial index of Who's On First records.
+type SpatialIndex struct {
+	places []*Place
+}
+
+// NewSpatialIndex returns a SpatialIndex containing all the current (not deprecated or superseded)
+// records with polygon or multipolygon geometries read from the whosonfirst/go-reader.Reader
+// defined by uri. Alternate geometry files are ignored. Because go-reader.Reader instances can not
+// list their contents only "fs://" readers are supported.
+func NewSpatialIndex(ctx context.Context, uri string) (*SpatialIndex, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "fs" {
+		return nil, fmt.Errorf("Unsupported reader scheme '%s'. Only fs:// readers can be indexed", u.Scheme)
+	}
+
+	r, err := reader.NewReader(ctx, uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	root := r.URI("")
+
+	idx := &SpatialIndex{
+		places: make([]*Place, 0),
+	}
+
+	walk_func := func(path string, info os.FileInfo, err error) error {
+
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		is_wof, err := wof_uri.IsWOFFile(path)
+
+		if err != nil || !is_wof {
+			return nil
+		}
+
+		is_alt, err := wof_uri.IsAltFile(path)
+
+		if err != nil || is_alt {
+			return nil
+		}
+
+		rel_path, err := filepath.Rel(root, path)
+
+		if err != nil {
+			return err
+		}
+
+		fh, err := r.Read(ctx, rel_path)
+
+		if err != nil {
+			return err
+		}
+
+		defer fh.Close()
+
+		body, err := ioutil.ReadAll(fh)
+
+		if err != nil {
+			return err
+		}
+
+		p, err := NewPlace(body)
+
+		if err != nil {
+			return fmt.Errorf("Failed to index %s, %v", path, err)
+		}
+
+		if p != nil {
+			idx.places = append(idx.places, p)
+		}
+
+		return nil
+	}
+
+	err = filepath.Walk(root, walk_func)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return idx, nil
+}
+
+// NewPlace returns a Place for the encoded Who's On First record body or nil if the record is not
+// current or does not have a polygon or multipolygon geometry.
+func NewPlace(body []byte) (*Place, error) {
+
+	deprecated_rsp := gjson.GetBytes(body, "properties.edtf:deprecated")
+
+	if deprecated_rsp.Exists() && deprecated_rsp.String() != "" {
+		return nil, nil
+	}
+
+	superseded_rsp := gjson.GetBytes(body, "properties.wof:superseded_by")
+
+	if superseded_rsp.Exists() && len(superseded_rsp.Array()) > 0 {
+		return nil, nil
+	}
+
+	geom_rsp := gjson.GetBytes(body, "geometry")
+
+	if !geom_rsp.Exists() {
+		return nil, errors.New("Missing geometry")
+	}
+
+	switch geom_rsp.Get("type").String() {
+	case "Polygon", "MultiPolygon":
+		// pass
+	default:
+		return nil, nil
+	}
+
+	g, err := geojson.UnmarshalGeometry([]byte(geom_rsp.Raw))
+
+	if err != nil {
+		return nil, err
+	}
+
+	id_rsp := gjson.GetBytes(body, "properties.wof:id")
+
+	if !id_rsp.Exists() {
+		return nil, errors.New("Missing wof:id")
+	}
+
+	geom := g.Geometry()
+
+	p := &Place{
+		Id:        id_rsp.Int(),
+		Name:      gjson.GetBytes(body, "properties.wof:name").String(),
+		Placetype: gjson.GetBytes(body, "properties.wof:placetype").String(),
+		ParentId:  -1,
+		Hierarchy: Hierarchy(body),
+		Geometry:  geom,
+		Bound:     geom.Bound(),
+		Area:      planar.Area(geom),
+	}
+
+	parent_rsp := gjson.GetBytes(body, "properties.wof:parent_id")
+
+	if parent_rsp.Exists() {
+		p.ParentId = parent_rsp.Int()
+	}
+
+	return p, nil
+}
+
+// Hierarchy returns the "wof:hierarchy" property of the encoded Who's On First record body.
+func Hierarchy(body []byte) []map[string]int64 {
+
+	hierarchy := make([]map[string]int64, 0)
+
+	for _, h := range gjson.GetBytes(body, "properties.wof:hierarchy").Array() {
+
+		m := make(map[string]int64)
+
+		for k, v := range h.Map() {
+			m[k] = v.Int()
+		}
+
+		hierarchy = append(hierarchy, m)
+	}
+
+	return hierarchy
+}
+
+// Count returns the number of places in the index.
+func (idx *SpatialIndex) Count() int {
+	return len(idx.places)
+}
+
+// PointInPolygon returns the places containing pt ordered from the most to the least specific
+// (smallest to largest area). If placetypes is not empty only places with those placetypes are
+// returned.
+func (idx *SpatialIndex) PointInPolygon(ctx context.Context, pt orb.Point, placetypes ...string) ([]*Place, error) {
+
+	results := make([]*Place, 0)
+
+	for _, p := range idx.places {
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+			// pass
+		}
+
+		if !hasPlacetype(p, placetypes) {
+			continue
+		}
+
+		if p.Contains(pt) {
+			results = append(results, p)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Area < results[j].Area
+	})
+
+	return results, nil
+}
+
+func hasPlacetype(p *Place, placetypes []string) bool {
+
+	if len(placetypes) == 0 {
+		return true
+	}
+
+	for _, pt := range placetypes {
+
+		if strings.TrimSpace(pt) == p.Placetype {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Intersection is a place whose geometry intersects a polygon.
+type Intersection struct {
+	Place *Place
+	// Coverage is an estimate (0.0 to 1.0) of the fraction of the polygon covered by the place.
+	Coverage float64
+}
+
+// the number of rows and columns of points used to sample polygons in Intersects
+const intersects_samples int = 32
+
+// Intersects returns the places whose geometries intersect poly ordered by the fraction of poly
+// they cover (estimated by sampling a grid of points inside poly) and then from the most to the
+// least specific (smallest to largest area). If placetypes is not empty only places with those
+// placetypes are returned.
+func (idx *SpatialIndex) Intersects(ctx context.Context, poly orb.Polygon, placetypes ...string) ([]*Intersection, error) {
+
+	b := poly.Bound()
+
+	samples := make([]orb.Point, 0)
+
+	dx := (b.Max.X() - b.Min.X()) / float64(intersects_samples)
+	dy := (b.Max.Y() - b.Min.Y()) / float64(intersects_samples)
+
+	for i := 0; i < intersects_samples; i++ {
+
+		for j := 0; j < intersects_samples; j++ {
+
+			pt := orb.Point{
+				b.Min.X() + (dx * (float64(i) + 0.5)),
+				b.Min.Y() + (dy * (float64(j) + 0.5)),
+			}
+
+			if planar.PolygonContains(poly, pt) {
+				samples = append(samples, pt)
+			}
+		}
+	}
+
+	results := make([]*Intersection, 0)
+
+	for _, p := range idx.places {
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+			// pass
+		}
+
+		if !hasPlacetype(p, placetypes) {
+			continue
+		}
+
+		if !p.Bound.Intersects(b) {
+			continue
+		}
+
+		count := 0
+
+		for _, pt := range samples {
+
+			if p.Contains(pt) {
+				count += 1
+			}
+		}
+
+		if count == 0 && !intersects(p, poly) {
+			continue
+		}
+
+		coverage := 0.0
+
+		if len(samples) > 0 {
+			coverage = float64(count) / float64(len(samples))
+		}
+
+		i := &Intersection{
+			Place:    p,
+			Coverage: coverage,
+		}
+
+		results = append(results, i)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+
+		if results[i].Coverage != results[j].Coverage {
+			return results[i].Coverage > results[j].Coverage
+		}
+
+		return results[i].Place.Area < results[j].Place.Area
+	})
+
+	return results, nil
+}
+
+// intersects reports whether any vertex of poly is inside p or any vertex of p is inside poly. It
+// is used to catch places too small to contain any of the sample points in Intersects.
+func intersects(p *Place, poly orb.Polygon) bool {
+
+	for _, r := range poly {
+
+		for _, pt := range r {
+
+			if p.Contains(pt) {
+				return true
+			}
+		}
+	}
+
+	var polys []orb.Polygon
+
+	switch g := p.Geometry.(type) {
+	case orb.Polygon:
+		polys = []orb.Polygon{g}
+	case orb.MultiPolygon:
+		polys = g
+	}
+
+	for _, p_poly := range polys {
+
+		for _, r := range p_poly {
+
+			for _, pt := range r {
+
+				if planar.PolygonContains(poly, pt) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
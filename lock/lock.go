@@ -0,0 +1,202 @@
+// package lock provides per-ID locks for serializing writes to Who's On First records, both within a
+// single process and (using lockfiles) across processes sharing a filesystem.
+package lock
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LOCKFILE_EXTENSION is the extension of the lockfiles created by AcquireLockfile.
+const LOCKFILE_EXTENSION string = ".lock"
+
+// LockTimeoutError is returned when a lock can not be acquired before a timeout.
+type LockTimeoutError struct {
+	Id int64
+}
+
+func (e *LockTimeoutError) Error() string {
+	return fmt.Sprintf("Timed out waiting for lock on %d", e.Id)
+}
+
+type idLock struct {
+	ch    chan bool
+	count int
+}
+
+// IdLocks provides in-process, per-ID locks.
+type IdLocks struct {
+	mu    *sync.Mutex
+	locks map[int64]*idLock
+}
+
+func NewIdLocks() *IdLocks {
+
+	l := &IdLocks{
+		mu:    new(sync.Mutex),
+		locks: make(map[int64]*idLock),
+	}
+
+	return l
+}
+
+// Lock blocks until the lock for id has been acquired or ctx is done. It returns a function
+// that must be called to release the lock.
+func (l *IdLocks) Lock(ctx context.Context, id int64) (func(), error) {
+
+	l.mu.Lock()
+
+	lk, ok := l.locks[id]
+
+	if !ok {
+
+		lk = &idLock{
+			ch: make(chan bool, 1),
+		}
+
+		l.locks[id] = lk
+	}
+
+	lk.count += 1
+	l.mu.Unlock()
+
+	select {
+	case lk.ch <- true:
+		// pass
+	case <-ctx.Done():
+		l.release(id, lk)
+		return nil, &LockTimeoutError{Id: id}
+	}
+
+	unlock := func() {
+		<-lk.ch
+		l.release(id, lk)
+	}
+
+	return unlock, nil
+}
+
+func (l *IdLocks) release(id int64, lk *idLock) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lk.count -= 1
+
+	if lk.count == 0 {
+		delete(l.locks, id)
+	}
+}
+
+// LockfilePath returns the path of the lockfile for id in the same directory as the record at path.
+func LockfilePath(path string, id int64) string {
+	return LockfileDirectoryPath(filepath.Dir(path), id)
+}
+
+// LockfileDirectoryPath returns the path of the lockfile for id in the directory root.
+func LockfileDirectoryPath(root string, id int64) string {
+	fname := strconv.FormatInt(id, 10) + LOCKFILE_EXTENSION
+	return filepath.Join(root, fname)
+}
+
+// AcquireLockfile creates the lockfile at path, containing the current process ID, retrying until it
+// succeeds or ctx is done. Lockfiles older than stale (if greater than 0) are assumed to have been
+// abandoned and are taken over (see takeOverLockfile). It returns a function that must be called to
+// remove the lockfile.
+func AcquireLockfile(ctx context.Context, path string, id int64, stale time.Duration) (func() error, error) {
+
+	for {
+
+		fh, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+
+		if err == nil {
+
+			fmt.Fprintf(fh, "%d\n", os.Getpid())
+
+			err = fh.Close()
+
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+
+			unlock := func() error {
+				return os.Remove(path)
+			}
+
+			return unlock, nil
+		}
+
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if stale > 0 && isStale(path, stale) {
+
+			err := takeOverLockfile(path, stale)
+
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, &LockTimeoutError{Id: id}
+		case <-time.After(100 * time.Millisecond):
+			// pass
+		}
+	}
+}
+
+// takeOverLockfile removes the stale lockfile at path. Rather than removing path directly, which might
+// remove a lockfile that another process has just created in place of the stale one, the lockfile is first
+// renamed to a name unique to this process. Renames are atomic so only one process can claim a given
+// lockfile and, since renaming preserves the modification time, the claimed lockfile can be checked again.
+// If it is no longer stale it is put back (unless another lockfile has been created in the meantime).
+func takeOverLockfile(path string, stale time.Duration) error {
+
+	claimed := fmt.Sprintf("%s.%d.%d", path, os.Getpid(), time.Now().UnixNano())
+
+	err := os.Rename(path, claimed)
+
+	if err != nil {
+
+		// another process claimed (or released) the lockfile first
+
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	if !isStale(claimed, stale) {
+
+		err := os.Link(claimed, path)
+
+		if err != nil && !os.IsExist(err) {
+			return err
+		}
+	}
+
+	return os.Remove(claimed)
+}
+
+func isStale(path string, stale time.Duration) bool {
+
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return false
+	}
+
+	return time.Since(info.ModTime()) > stale
+}
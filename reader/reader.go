@@ -0,0 +1,79 @@
+// package reader provides a common interface for reading geotag features, the counterpart to the
+// go-www-geotag/writer package.
+package reader
+
+import (
+	"context"
+	"github.com/aaronland/go-roster"
+	"github.com/sfomuseum/go-geojson-geotag"
+	"net/url"
+)
+
+type Reader interface {
+	ReadFeature(context.Context, string) (*geotag.GeotagFeature, error)
+	Close(context.Context) error
+}
+
+// ETagReader is implemented by readers that can return an entity tag for a geotag, for use with
+// optimistic concurrency control when writing geotags. The geotag and its entity tag are derived from
+// the same read so that the entity tag always describes the geotag that was returned.
+type ETagReader interface {
+	ReadFeatureWithETag(context.Context, string) (*geotag.GeotagFeature, string, error)
+}
+
+type ReaderInitializeFunc func(ctx context.Context, uri string) (Reader, error)
+
+var readers roster.Roster
+
+func ensureRoster() error {
+
+	if readers == nil {
+
+		r, err := roster.NewDefaultRoster()
+
+		if err != nil {
+			return err
+		}
+
+		readers = r
+	}
+
+	return nil
+}
+
+func RegisterReader(ctx context.Context, scheme string, f ReaderInitializeFunc) error {
+
+	err := ensureRoster()
+
+	if err != nil {
+		return err
+	}
+
+	return readers.Register(ctx, scheme, f)
+}
+
+func NewReader(ctx context.Context, uri string) (Reader, error) {
+
+	err := ensureRoster()
+
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := u.Scheme
+
+	i, err := readers.Driver(ctx, scheme)
+
+	if err != nil {
+		return nil, err
+	}
+
+	f := i.(ReaderInitializeFunc)
+	return f(ctx, uri)
+}
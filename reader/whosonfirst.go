@@ -0,0 +1,375 @@
+package reader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/paulmach/orb"
+	"github.com/sfomuseum/go-geojson-geotag"
+	"github.com/sfomuseum/go-www-geotag-whosonfirst/geo"
+	"github.com/sfomuseum/go-www-geotag-whosonfirst/writer"
+	"github.com/tidwall/gjson"
+	wof_reader "github.com/whosonfirst/go-reader"
+	wof_uri "github.com/whosonfirst/go-whosonfirst-uri"
+	"io/ioutil"
+	"math"
+	"net/url"
+)
+
+func init() {
+	ctx := context.Background()
+	RegisterReader(ctx, "whosonfirst", NewWhosOnFirstGeotagReader)
+}
+
+// WhosOnFirstGeotagReader reads the geotag alt files produced by writer.WhosOnFirstGeotagWriter.
+type WhosOnFirstGeotagReader struct {
+	Reader
+	reader            wof_reader.Reader
+	names             *writer.GeotagNames
+	superseded_policy string
+}
+
+func NewWhosOnFirstGeotagReader(ctx context.Context, uri string) (Reader, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+
+	reader_uri := q.Get("reader")
+
+	if reader_uri == "" {
+		return nil, errors.New("Missing reader parameter")
+	}
+
+	reader_uri, err = url.QueryUnescape(reader_uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	wof_rd, err := wof_reader.NewReader(ctx, reader_uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// these need to match the "label" and "namespace" parameters of the writer
+
+	names, err := writer.NewGeotagNames(q.Get("label"), q.Get("namespace"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	// this needs to match the "superseded" parameter of the writer so that the geotag (and entity tag)
+	// returned for a superseded record are those of the record the writer will geotag
+
+	superseded_policy := writer.SUPERSEDED_REFUSE
+
+	q_superseded := q.Get("superseded")
+
+	if q_superseded != "" {
+
+		switch q_superseded {
+		case writer.SUPERSEDED_REFUSE, writer.SUPERSEDED_FOLLOW:
+			superseded_policy = q_superseded
+		default:
+			return nil, errors.New("Invalid superseded parameter")
+		}
+	}
+
+	rd := &WhosOnFirstGeotagReader{
+		reader:            wof_rd,
+		names:             names,
+		superseded_policy: superseded_policy,
+	}
+
+	return rd, nil
+}
+
+// ReadFeature reads the geotag alt file for uri (and the optional "geotag" request parameter
+// assigned to ctx) and reconstructs a geotag.GeotagFeature from its geotag properties.
+func (rd *WhosOnFirstGeotagReader) ReadFeature(ctx context.Context, uri string) (*geotag.GeotagFeature, error) {
+
+	f, _, err := rd.ReadFeatureWithETag(ctx, uri)
+	return f, err
+}
+
+// ReadFeatureWithETag returns the geotag.GeotagFeature for uri, like ReadFeature, and its entity tag, as
+// computed by writer.GeotagETag, derived from the same documents. The entity tag can be passed back to the
+// writer (using writer.SetIfMatchWithContext) to ensure that the geotag has not been modified by someone
+// else in the meantime.
+func (rd *WhosOnFirstGeotagReader) ReadFeatureWithETag(ctx context.Context, uri string) (*geotag.GeotagFeature, string, error) {
+
+	alt_path, record_paths, err := rd.paths(ctx, uri)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	alt_body, err := rd.read(ctx, alt_path)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	f, err := GeotagFeatureFromAltBody(alt_body, rd.names)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	bodies := make([][]byte, 0)
+
+	for _, path := range record_paths {
+
+		body, err := rd.read(ctx, path)
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		bodies = append(bodies, body)
+	}
+
+	if len(bodies) == 1 {
+		bodies = append(bodies, nil)
+	}
+
+	bodies = append(bodies, alt_body)
+
+	return f, writer.GeotagETag(bodies...), nil
+}
+
+// paths returns the path of the geotag alt file for uri and the paths of the main record and, if uri
+// is an alternate geometry, the alternate geometry.
+func (rd *WhosOnFirstGeotagReader) paths(ctx context.Context, uri string) (string, []string, error) {
+
+	wof_id, uri_args, err := wof_uri.ParseURI(uri)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	params, err := writer.GetRequestParametersFromContext(ctx)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	geotag_label, err := rd.names.AltLabel(uri_args, params.Get("geotag"))
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	if rd.superseded_policy == writer.SUPERSEDED_FOLLOW {
+
+		wof_id, err = rd.followSuperseded(ctx, wof_id, uri_args)
+
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	alt_uri_args := wof_uri.NewAlternateURIArgs(geotag_label, "")
+
+	alt_path, err := wof_uri.Id2RelPath(wof_id, alt_uri_args)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	rel_path, err := wof_uri.Id2RelPath(wof_id)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	record_paths := []string{
+		rel_path,
+	}
+
+	if uri_args.IsAlternate {
+
+		record_path, err := wof_uri.Id2RelPath(wof_id, uri_args)
+
+		if err != nil {
+			return "", nil, err
+		}
+
+		record_paths = append(record_paths, record_path)
+	}
+
+	return alt_path, record_paths, nil
+}
+
+// followSuperseded returns the ID of the record that (ultimately) supersedes the record wof_id, or wof_id
+// if it has not been superseded.
+func (rd *WhosOnFirstGeotagReader) followSuperseded(ctx context.Context, wof_id int64, uri_args *wof_uri.URIArgs) (int64, error) {
+
+	rel_path, err := wof_uri.Id2RelPath(wof_id)
+
+	if err != nil {
+		return 0, err
+	}
+
+	body, err := rd.read(ctx, rel_path)
+
+	if err != nil {
+		return 0, err
+	}
+
+	followed, err := writer.FollowSuperseded(ctx, wof_id, body, uri_args.IsAlternate, rd.read)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return followed.Id, nil
+}
+
+func (rd *WhosOnFirstGeotagReader) read(ctx context.Context, path string) ([]byte, error) {
+
+	fh, err := rd.reader.Read(ctx, path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer fh.Close()
+
+	return ioutil.ReadAll(fh)
+}
+
+func (rd *WhosOnFirstGeotagReader) Close(ctx context.Context) error {
+	return nil
+}
+
+// GeotagFeatureFromAltBody reconstructs a geotag.GeotagFeature from the properties, named according
+// to names, of an encoded geotag alt file.
+func GeotagFeatureFromAltBody(body []byte, names *writer.GeotagNames) (*geotag.GeotagFeature, error) {
+
+	values := make(map[string]float64)
+
+	for _, k := range []string{"camera_longitude", "camera_latitude", "angle", "bearing", "distance"} {
+
+		path := fmt.Sprintf("properties.%s", names.Property(k))
+		rsp := gjson.GetBytes(body, path)
+
+		if !rsp.Exists() {
+			return nil, fmt.Errorf("Missing %s property", path)
+		}
+
+		values[k] = rsp.Float()
+	}
+
+	camera := orb.Point{values["camera_longitude"], values["camera_latitude"]}
+	angle := values["angle"]
+	bearing := values["bearing"]
+	distance := values["distance"]
+
+	// the client-supplied bearing and distance are returned, if present, so that
+	// the feature is the same as the one that was written
+
+	client_bearing_rsp := gjson.GetBytes(body, fmt.Sprintf("properties.%s", names.Property("client_bearing")))
+
+	if client_bearing_rsp.Exists() {
+		bearing = client_bearing_rsp.Float()
+	}
+
+	client_distance_rsp := gjson.GetBytes(body, fmt.Sprintf("properties.%s", names.Property("client_distance")))
+
+	if client_distance_rsp.Exists() {
+		distance = client_distance_rsp.Float()
+	}
+
+	left, right, err := horizonFromAltBody(body, names, camera, values["bearing"], angle, values["distance"])
+
+	if err != nil {
+		return nil, err
+	}
+
+	pov := &geotag.GeotagPoint{
+		Type:        "Point",
+		Coordinates: geotag.GeotagCoordinate(camera),
+	}
+
+	hl := &geotag.GeotagLineString{
+		Type: "LineString",
+		Coordinates: [2]geotag.GeotagCoordinate{
+			geotag.GeotagCoordinate(left),
+			geotag.GeotagCoordinate(right),
+		},
+	}
+
+	f := &geotag.GeotagFeature{
+		Type: "Feature",
+		Geometry: geotag.GeotagGeometryCollection{
+			Type:       "GeometryCollection",
+			Geometries: [2]interface{}{pov, hl},
+		},
+		Properties: geotag.GeotagProperties{
+			Angle:    angle,
+			Bearing:  bearing,
+			Distance: distance,
+		},
+	}
+
+	id_rsp := gjson.GetBytes(body, "properties.wof:id")
+
+	if id_rsp.Exists() {
+		f.Id = id_rsp.String()
+	}
+
+	return f, nil
+}
+
+// horizonFromAltBody returns the ends of the horizon line recorded in the "{NAMESPACE}horizon" property of
+// body. Alt files written before the property was assigned have their horizon line derived from the computed
+// bearing and distance to the target, which will not exactly match the horizon line that was written.
+func horizonFromAltBody(body []byte, names *writer.GeotagNames, camera orb.Point, bearing float64, angle float64, distance float64) (orb.Point, orb.Point, error) {
+
+	horizon_rsp := gjson.GetBytes(body, fmt.Sprintf("properties.%s", names.Property("horizon")))
+
+	if horizon_rsp.Exists() {
+
+		coords := horizon_rsp.Array()
+
+		if len(coords) != 2 || len(coords[0].Array()) != 2 || len(coords[1].Array()) != 2 {
+			return orb.Point{}, orb.Point{}, fmt.Errorf("Invalid %s property", names.Property("horizon"))
+		}
+
+		left := orb.Point{coords[0].Array()[0].Float(), coords[0].Array()[1].Float()}
+		right := orb.Point{coords[1].Array()[0].Float(), coords[1].Array()[1].Float()}
+
+		return left, right, nil
+	}
+
+	// for angles less than 180 degrees the horizon line is perpendicular to the
+	// bearing and passes through the target so its ends are further away
+
+	edge_distance := distance
+
+	if angle < 180.0 {
+		edge_distance = distance / math.Cos(angle/2.0*math.Pi/180.0)
+	}
+
+	left, err := geo.Direct(camera, bearing-(angle/2.0), edge_distance)
+
+	if err != nil {
+		return orb.Point{}, orb.Point{}, err
+	}
+
+	right, err := geo.Direct(camera, bearing+(angle/2.0), edge_distance)
+
+	if err != nil {
+		return orb.Point{}, orb.Point{}, err
+	}
+
+	return left, right, nil
+}
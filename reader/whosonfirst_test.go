@@ -0,0 +1,218 @@
+package reader
+
+import (
+	"context"
+	"fmt"
+	"github.com/paulmach/orb"
+	"github.com/sfomuseum/go-geojson-geotag"
+	"github.com/sfomuseum/go-www-geotag-whosonfirst/geo"
+	"github.com/sfomuseum/go-www-geotag-whosonfirst/writer"
+	"io/ioutil"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const test_main_path string = "123/456/7/1234567.geojson"
+const test_alt_path string = "123/456/7/1234567-alt-geotag-fov.geojson"
+
+// newTestData creates a temporary directory containing the record in fixtures/1234567.geojson, geotagged
+// with the feature in fixtures/test.geojson, and returns the geotag feature, the path of the directory and
+// a function to remove it.
+func newTestData(t *testing.T) (*geotag.GeotagFeature, string, func()) {
+
+	root, err := ioutil.TempDir("", "geotag")
+
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory, %v", err)
+	}
+
+	cleanup := func() {
+		os.RemoveAll(root)
+	}
+
+	record, err := ioutil.ReadFile("../fixtures/1234567.geojson")
+
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(filepath.Join(root, test_main_path)), 0755)
+	}
+
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(root, test_main_path), record, 0644)
+	}
+
+	if err != nil {
+		cleanup()
+		t.Fatalf("Failed to write record, %v", err)
+	}
+
+	body, err := ioutil.ReadFile("../fixtures/test.geojson")
+
+	if err != nil {
+		cleanup()
+		t.Fatalf("Failed to read geotag feature, %v", err)
+	}
+
+	geotag_f, err := geotag.NewGeotagFeature(body)
+
+	if err != nil {
+		cleanup()
+		t.Fatalf("Failed to parse geotag feature, %v", err)
+	}
+
+	q := url.Values{}
+	q.Set("writer", fmt.Sprintf("fs://%s", root))
+	q.Set("reader", fmt.Sprintf("fs://%s", root))
+	q.Set("update", "1")
+
+	ctx := context.Background()
+
+	wr, err := writer.NewWhosOnFirstGeotagWriter(ctx, fmt.Sprintf("whosonfirst://?%s", q.Encode()))
+
+	if err == nil {
+		err = wr.WriteFeature(ctx, "1234567", geotag_f)
+	}
+
+	if err != nil {
+		cleanup()
+		t.Fatalf("Failed to write geotag, %v", err)
+	}
+
+	return geotag_f, root, cleanup
+}
+
+func newTestReader(t *testing.T, root string) *WhosOnFirstGeotagReader {
+
+	q := url.Values{}
+	q.Set("reader", fmt.Sprintf("fs://%s", root))
+
+	rd, err := NewWhosOnFirstGeotagReader(context.Background(), fmt.Sprintf("whosonfirst://?%s", q.Encode()))
+
+	if err != nil {
+		t.Fatalf("Failed to create reader, %v", err)
+	}
+
+	return rd.(*WhosOnFirstGeotagReader)
+}
+
+func TestReadFeature(t *testing.T) {
+
+	expected, root, cleanup := newTestData(t)
+	defer cleanup()
+
+	rd := newTestReader(t, root)
+
+	geotag_f, err := rd.ReadFeature(context.Background(), "1234567")
+
+	if err != nil {
+		t.Fatalf("Failed to read geotag, %v", err)
+	}
+
+	// the feature that is read back is the feature that was written
+
+	if geotag_f.Properties != expected.Properties {
+		t.Errorf("Expected properties %v, got %v", expected.Properties, geotag_f.Properties)
+	}
+
+	pov, _ := geotag_f.PointOfView()
+	expected_pov, _ := expected.PointOfView()
+
+	if pov.Coordinates != expected_pov.Coordinates {
+		t.Errorf("Expected point of view %v, got %v", expected_pov.Coordinates, pov.Coordinates)
+	}
+
+	hl, _ := geotag_f.HorizonLine()
+	expected_hl, _ := expected.HorizonLine()
+
+	if hl.Coordinates != expected_hl.Coordinates {
+		t.Errorf("Expected horizon line %v, got %v", expected_hl.Coordinates, hl.Coordinates)
+	}
+
+	if geotag_f.Id != "1234567" {
+		t.Errorf("Expected ID 1234567, got '%s'", geotag_f.Id)
+	}
+
+	ctx, _ := writer.SetRequestParametersWithContext(context.Background(), url.Values{"geotag": []string{"other"}})
+
+	_, err = rd.ReadFeature(ctx, "1234567")
+
+	if !os.IsNotExist(err) {
+		t.Errorf("Expected a missing geotag to not exist, got %v", err)
+	}
+}
+
+func TestReadFeatureWithETag(t *testing.T) {
+
+	_, root, cleanup := newTestData(t)
+	defer cleanup()
+
+	rd := newTestReader(t, root)
+
+	geotag_f, etag, err := rd.ReadFeatureWithETag(context.Background(), "1234567")
+
+	if err != nil {
+		t.Fatalf("Failed to read geotag, %v", err)
+	}
+
+	if geotag_f.Id != "1234567" {
+		t.Errorf("Expected ID 1234567, got '%s'", geotag_f.Id)
+	}
+
+	main_body, _ := ioutil.ReadFile(filepath.Join(root, test_main_path))
+	alt_body, _ := ioutil.ReadFile(filepath.Join(root, test_alt_path))
+
+	expected := writer.GeotagETag(main_body, nil, alt_body)
+
+	if etag != expected {
+		t.Errorf("Expected entity tag %s, got %s", expected, etag)
+	}
+}
+
+func TestGeotagFeatureFromAltBody(t *testing.T) {
+
+	names := writer.DefaultGeotagNames()
+
+	camera := orb.Point{-122.0, 37.0}
+
+	// an alt file written before the horizon line was recorded
+
+	body := []byte(`{"properties":{"wof:id":1234567,"geotag:camera_longitude":-122.0,"geotag:camera_latitude":37.0,"geotag:angle":90,"geotag:bearing":45,"geotag:distance":1000}}`)
+
+	geotag_f, err := GeotagFeatureFromAltBody(body, names)
+
+	if err != nil {
+		t.Fatalf("Failed to derive geotag feature, %v", err)
+	}
+
+	hl, _ := geotag_f.HorizonLine()
+
+	// the ends of the horizon line are at bearing ± angle/2 and distance / cos(angle/2)
+
+	expected_bearings := []float64{0.0, 90.0}
+	expected_distance := 1000.0 / math.Cos(math.Pi/4.0)
+
+	for i, c := range hl.Coordinates {
+
+		distance, bearing, _, err := geo.Inverse(camera, orb.Point(c))
+
+		if err != nil {
+			t.Fatalf("Failed to derive inverse, %v", err)
+		}
+
+		if math.Abs(geo.NormalizeBearing(bearing-expected_bearings[i])) > 1e-6 {
+			t.Errorf("Expected end %d of the horizon line to have bearing %f, got %f", i, expected_bearings[i], bearing)
+		}
+
+		if math.Abs(distance-expected_distance) > 1e-3 {
+			t.Errorf("Expected end %d of the horizon line to be %fm away, got %f", i, expected_distance, distance)
+		}
+	}
+
+	_, err = GeotagFeatureFromAltBody([]byte(`{"properties":{"geotag:angle":90}}`), names)
+
+	if err == nil {
+		t.Errorf("Expected an alt file without a camera position to fail")
+	}
+}
@@ -0,0 +1,521 @@
+package writer
+
+import (
+	"context"
+	"fmt"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	wof_uri "github.com/whosonfirst/go-whosonfirst-uri"
+	"strings"
+	"time"
+)
+
+const DELETE_REMOVE string = "remove"
+const DELETE_DEPRECATE string = "deprecate"
+
+// Deleter is implemented by writers that can delete (revert) an existing geotag.
+type Deleter interface {
+	DeleteFeature(context.Context, string) error
+}
+
+// DeleteFeature removes (or deprecates, depending on the writer's delete policy) the geotag alt file
+// for uri, and any camera, target or horizon alt files, removes them from the main record's "src:geom_alt"
+// property and, if it is the primary geotag,
+// strips the geotag properties from the record and restores any geometry, label coordinates and
+// "src:geom" property that were saved when the record was updated. The lifecycle state of the record is handled
+// the same way as WriteFeature handles it. If there is no geotag alt file for uri an error satisfying
+// os.IsNotExist is returned.
+func (wr *WhosOnFirstGeotagWriter) DeleteFeature(ctx context.Context, uri string) error {
+
+	wof_id, uri_args, err := wof_uri.ParseURI(uri)
+
+	if err != nil {
+		return err
+	}
+
+	params, err := GetRequestParametersFromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	provenance, err := GetProvenanceFromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	geotag_label, err := wr.names.AltLabel(uri_args, params.Get("geotag"))
+
+	if err != nil {
+		return err
+	}
+
+	dryrun := wr.dryrun
+
+	switch params.Get("dryrun") {
+	case "":
+		// pass
+	case "1":
+		dryrun = true
+	case "0":
+		dryrun = false
+	default:
+		return newParameterError("dryrun", "must be 0 or 1")
+	}
+
+	override, err := wr.requestOverride(params)
+
+	if err != nil {
+		return err
+	}
+
+	rel_path, err := wof_uri.Id2RelPath(wof_id)
+
+	if err != nil {
+		return err
+	}
+
+	unlock, err := wr.lock(ctx, wof_id, rel_path)
+
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	main_body, err := wr.readFeature(ctx, rel_path)
+
+	if err != nil {
+		return err
+	}
+
+	// geotags are deleted from the same record that WriteFeature would geotag so superseded
+	// records may be replaced by the record that supersedes them
+
+	wof_id, rel_path, main_body, lifecycle, unlock_superseding, err := wr.resolveLockedLifecycle(ctx, wof_id, main_body, uri_args.IsAlternate, override)
+
+	if err != nil {
+		return err
+	}
+
+	defer unlock_superseding()
+
+	alt_uri_args := wof_uri.NewAlternateURIArgs(geotag_label, "")
+
+	alt_path, err := wof_uri.Id2RelPath(wof_id, alt_uri_args)
+
+	if err != nil {
+		return err
+	}
+
+	alt_body, err := wr.readFeature(ctx, alt_path)
+
+	if err != nil {
+		return err
+	}
+
+	record_path := rel_path
+	record_body := main_body
+
+	if uri_args.IsAlternate {
+
+		record_path, err = wof_uri.Id2RelPath(wof_id, uri_args)
+
+		if err != nil {
+			return err
+		}
+
+		record_body, err = wr.readFeature(ctx, record_path)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	var etag_record []byte
+
+	if uri_args.IsAlternate {
+		etag_record = record_body
+	}
+
+	etag := GeotagETag(main_body, etag_record, alt_body)
+
+	err = checkPreconditions(ctx, wof_id, etag, true)
+
+	if err != nil {
+		return err
+	}
+
+	alt_staged := &stagedFeature{
+		Role:     STAGED_ALT,
+		Path:     alt_path,
+		Previous: alt_body,
+	}
+
+	// any additional (camera, target, horizon) geometries are removed (or deprecated) with the geotag
+
+	geometries, geometry_labels, err := wr.existingAltGeometries(ctx, wof_id, uri_args, params.Get("geotag"))
+
+	if err != nil {
+		return err
+	}
+
+	staged := append([]*stagedFeature{alt_staged}, geometries...)
+
+	switch wr.delete_policy {
+	case DELETE_DEPRECATE:
+
+		now := time.Now()
+
+		for _, f := range staged {
+
+			f.Body, err = deprecateAlt(f.Previous, now, provenance.DeletedProperties(wr.names))
+
+			if err != nil {
+				return err
+			}
+		}
+
+	default:
+		// a nil body means the document will be removed
+	}
+
+	main_previous := main_body
+	record_previous := record_body
+
+	is_primary := gjson.GetBytes(record_body, fmt.Sprintf("properties.%s", wr.names.Property("primary_label"))).String() == geotag_label
+
+	if is_primary {
+
+		if !uri_args.IsAlternate {
+
+			record_body, err = wr.restorePregeotag(ctx, wof_id, record_body)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		record_body, err = revertProperties(record_body, wr.names, !uri_args.IsAlternate)
+
+		if err != nil {
+			return err
+		}
+
+		if uri_args.IsAlternate {
+
+			record_body, err = FormatAltBody(record_body)
+
+			if err != nil {
+				return err
+			}
+
+			staged = append(staged, &stagedFeature{
+				Role:     STAGED_RECORD,
+				Path:     record_path,
+				Body:     record_body,
+				Previous: record_previous,
+			})
+
+		} else {
+			main_body = record_body
+		}
+	}
+
+	main_body, removed, err := removeGeomAlt(main_body, geotag_label)
+
+	if err != nil {
+		return err
+	}
+
+	for _, label := range geometry_labels {
+
+		var geometry_removed bool
+
+		main_body, geometry_removed, err = removeGeomAlt(main_body, label)
+
+		if err != nil {
+			return err
+		}
+
+		removed = removed || geometry_removed
+	}
+
+	if removed || (is_primary && !uri_args.IsAlternate) {
+
+		main_body, err = ExportFeature(main_body)
+
+		if err != nil {
+			return err
+		}
+
+		staged = append(staged, &stagedFeature{
+			Role:     STAGED_MAIN,
+			Path:     rel_path,
+			Body:     main_body,
+			Previous: main_previous,
+		})
+	}
+
+	if dryrun {
+		return wr.writePreview(ctx, staged, lifecycle)
+	}
+
+	err = wr.commit(ctx, staged)
+
+	if err != nil {
+		return err
+	}
+
+	if uri_args.IsAlternate {
+		etag_record = record_body
+	}
+
+	report := &WhosOnFirstGeotagReport{
+		Id:        wof_id,
+		Status:    STATUS_DELETED,
+		ETag:      GeotagETag(main_body, etag_record, alt_staged.Body),
+		RequestId: provenance.RequestId,
+		Lifecycle: lifecycle,
+	}
+
+	return wr.writeReport(ctx, report)
+}
+
+// deprecateAlt assigns an "edtf:deprecated" property, for the date of now, and the provenance properties
+// in provenance_props to the alt file body.
+func deprecateAlt(body []byte, now time.Time, provenance_props map[string]interface{}) ([]byte, error) {
+
+	body, err := sjson.SetBytes(body, "properties.edtf:deprecated", now.Format("2006-01-02"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	body, err = setProperties(body, provenance_props)
+
+	if err != nil {
+		return nil, err
+	}
+
+	body, err = sjson.SetBytes(body, "properties.wof:lastmodified", now.Unix())
+
+	if err != nil {
+		return nil, err
+	}
+
+	return FormatAltBody(body)
+}
+
+// savePreviousProperties records the label coordinates (and "src:geom", "wof:parent_id" and "wof:hierarchy"
+// properties, if save_source is true) of body in "{NAMESPACE}previous_*" properties so that they can be restored if the geotag is deleted. Records
+// that have already been geotagged are left untouched so that the original values are preserved.
+func savePreviousProperties(body []byte, names *GeotagNames, save_source bool) ([]byte, error) {
+
+	if gjson.GetBytes(body, fmt.Sprintf("properties.%s", names.Property("primary_label"))).Exists() {
+		return body, nil
+	}
+
+	to_save := map[string]string{
+		"lbl:latitude":  names.Property("previous_lbl_latitude"),
+		"lbl:longitude": names.Property("previous_lbl_longitude"),
+	}
+
+	if save_source {
+		to_save["src:geom"] = names.Property("previous_src_geom")
+		to_save["wof:parent_id"] = names.Property("previous_parent_id")
+		to_save["wof:hierarchy"] = names.Property("previous_hierarchy")
+	}
+
+	var err error
+
+	for k, previous_k := range to_save {
+
+		rsp := gjson.GetBytes(body, fmt.Sprintf("properties.%s", k))
+
+		if !rsp.Exists() {
+			continue
+		}
+
+		body, err = sjson.SetBytes(body, fmt.Sprintf("properties.%s", previous_k), rsp.Value())
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return body, nil
+}
+
+// saveMappedProperties records the current values of the properties in to_update and to_remove, other than
+// geotag properties and the label coordinates and "src:geom" properties saved by savePreviousProperties, in
+// the "{NAMESPACE}previous_properties" property of body so that they can be restored if the geotag is deleted.
+// Properties that body does not have are recorded as null. Properties that have already been recorded by an
+// earlier geotag are left untouched so that the original values are preserved.
+func saveMappedProperties(body []byte, names *GeotagNames, to_update map[string]interface{}, to_remove []string) ([]byte, error) {
+
+	previous_k := fmt.Sprintf("properties.%s", names.Property("previous_properties"))
+
+	previous := make(map[string]interface{})
+
+	gjson.GetBytes(body, previous_k).ForEach(func(k gjson.Result, v gjson.Result) bool {
+		previous[k.String()] = v.Value()
+		return true
+	})
+
+	keys := make([]string, 0)
+
+	for k, _ := range to_update {
+		keys = append(keys, k)
+	}
+
+	keys = append(keys, to_remove...)
+
+	changed := false
+
+	for _, k := range keys {
+
+		if names.IsProperty(k) || k == "lbl:latitude" || k == "lbl:longitude" || k == "src:geom" {
+			continue
+		}
+
+		_, ok := previous[k]
+
+		if ok {
+			continue
+		}
+
+		rsp := gjson.GetBytes(body, fmt.Sprintf("properties.%s", k))
+
+		if rsp.Exists() {
+			previous[k] = rsp.Value()
+		} else {
+
+			// properties that are removed but were never there are not recorded
+
+			_, ok := to_update[k]
+
+			if !ok {
+				continue
+			}
+
+			previous[k] = nil
+		}
+
+		changed = true
+	}
+
+	if !changed {
+		return body, nil
+	}
+
+	return sjson.SetBytes(body, previous_k, previous)
+}
+
+// revertProperties restores the values saved by savePreviousProperties and saveMappedProperties and removes
+// all the geotag properties from body. Label coordinates, and other properties, that were added by the geotag
+// (rather than replaced) are removed. Properties that the geotag did not set are left untouched.
+func revertProperties(body []byte, names *GeotagNames, restore_source bool) ([]byte, error) {
+
+	to_restore := map[string]string{
+		"lbl:latitude":  names.Property("previous_lbl_latitude"),
+		"lbl:longitude": names.Property("previous_lbl_longitude"),
+	}
+
+	if restore_source {
+		to_restore["src:geom"] = names.Property("previous_src_geom")
+		to_restore["wof:parent_id"] = names.Property("previous_parent_id")
+		to_restore["wof:hierarchy"] = names.Property("previous_hierarchy")
+	}
+
+	var err error
+
+	for k, previous_k := range to_restore {
+
+		rsp := gjson.GetBytes(body, fmt.Sprintf("properties.%s", previous_k))
+
+		if rsp.Exists() {
+			body, err = sjson.SetBytes(body, fmt.Sprintf("properties.%s", k), rsp.Value())
+		} else if strings.HasPrefix(k, "lbl:") {
+			body, err = sjson.DeleteBytes(body, fmt.Sprintf("properties.%s", k))
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	to_update := make(map[string]interface{})
+	to_remove := make([]string, 0)
+
+	gjson.GetBytes(body, fmt.Sprintf("properties.%s", names.Property("previous_properties"))).ForEach(func(k gjson.Result, v gjson.Result) bool {
+
+		if v.Type == gjson.Null {
+			to_remove = append(to_remove, k.String())
+		} else {
+			to_update[k.String()] = v.Value()
+		}
+
+		return true
+	})
+
+	gjson.GetBytes(body, "properties").ForEach(func(k gjson.Result, v gjson.Result) bool {
+
+		if names.IsProperty(k.String()) {
+			to_remove = append(to_remove, k.String())
+		}
+
+		return true
+	})
+
+	body, err = setProperties(body, to_update)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return removeProperties(body, to_remove)
+}
+
+// removeGeomAlt removes label from the "src:geom_alt" property of body (removing the property entirely
+// if it is empty), returning the updated body and a boolean indicating whether label was present.
+func removeGeomAlt(body []byte, label string) ([]byte, bool, error) {
+
+	geom_alt_rsp := gjson.GetBytes(body, "properties.src:geom_alt")
+
+	if !geom_alt_rsp.Exists() {
+		return body, false, nil
+	}
+
+	geom_alt := make([]string, 0)
+	removed := false
+
+	for _, r := range geom_alt_rsp.Array() {
+
+		if r.String() == label {
+			removed = true
+			continue
+		}
+
+		geom_alt = append(geom_alt, r.String())
+	}
+
+	if !removed {
+		return body, false, nil
+	}
+
+	var err error
+
+	if len(geom_alt) > 0 {
+		body, err = sjson.SetBytes(body, "properties.src:geom_alt", geom_alt)
+	} else {
+		body, err = sjson.DeleteBytes(body, "properties.src:geom_alt")
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return body, true, nil
+}
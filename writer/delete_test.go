@@ -0,0 +1,152 @@
+package writer
+
+import (
+	"context"
+	"github.com/tidwall/gjson"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestSaveMappedProperties(t *testing.T) {
+
+	names := DefaultGeotagNames()
+
+	body := []byte(`{"properties":{"wof:depicts":[42],"lbl:latitude":37.6}}`)
+
+	to_update := map[string]interface{}{
+		"wof:depicts":            []int64{1, 2},
+		"sfomuseum:bearing":      90.0,
+		"lbl:latitude":           37.7,
+		"geotag:camera_latitude": 37.7,
+	}
+
+	to_remove := []string{"sfomuseum:never_set"}
+
+	body, err := saveMappedProperties(body, names, to_update, to_remove)
+
+	if err != nil {
+		t.Fatalf("Failed to save mapped properties, %v", err)
+	}
+
+	previous := gjson.GetBytes(body, "properties.geotag:previous_properties")
+
+	expected := `{"sfomuseum:bearing":null,"wof:depicts":[42]}`
+
+	if previous.Raw != expected {
+		t.Fatalf("Expected previous properties to be %s, got %s", expected, previous.Raw)
+	}
+
+	// values saved by an earlier geotag are preserved
+
+	body = []byte(`{"properties":{"wof:depicts":[1,2],"geotag:previous_properties":{"wof:depicts":[42]}}}`)
+
+	body, err = saveMappedProperties(body, names, map[string]interface{}{"wof:depicts": []int64{3}}, nil)
+
+	if err != nil {
+		t.Fatalf("Failed to save mapped properties, %v", err)
+	}
+
+	previous = gjson.GetBytes(body, "properties.geotag:previous_properties")
+
+	if previous.Raw != `{"wof:depicts":[42]}` {
+		t.Errorf("Expected the original wof:depicts to be preserved, got %s", previous.Raw)
+	}
+}
+
+func TestDeleteFeature(t *testing.T) {
+
+	tests := []struct {
+		Name   string
+		Record map[string]interface{}
+	}{
+		{
+			Name: "Replaced properties",
+			Record: map[string]interface{}{
+				"wof:depicts":       []int64{42},
+				"sfomuseum:bearing": 1.0,
+			},
+		},
+		{
+			Name: "Added properties",
+			Record: map[string]interface{}{
+				"wof:depicts": []int64{42},
+			},
+		},
+	}
+
+	for _, test := range tests {
+
+		root, cleanup := newTestRoot(t)
+		defer cleanup()
+
+		record, err := setProperties(readTestRecord(t), test.Record)
+
+		if err != nil {
+			t.Fatalf("%s: failed to update record, %v", test.Name, err)
+		}
+
+		writeTestFeature(t, root, test_main_path, record)
+
+		wr := newTestWriter(t, root, url.Values{
+			"update":         []string{"1"},
+			"add_properties": []string{"sfomuseum:bearing=bearing"},
+		})
+
+		ctx := context.Background()
+
+		writeTestGeotag(t, ctx, wr)
+
+		geotagged := readTestFeature(t, root, test_main_path)
+
+		if gjson.GetBytes(geotagged, "properties.sfomuseum:bearing").Float() == 1.0 {
+			t.Errorf("%s: expected sfomuseum:bearing to be assigned", test.Name)
+		}
+
+		err = wr.DeleteFeature(ctx, "1234567")
+
+		if err != nil {
+			t.Fatalf("%s: failed to delete geotag, %v", test.Name, err)
+		}
+
+		if readTestFeature(t, root, test_alt_path) != nil {
+			t.Errorf("%s: expected the geotag alt file to be removed", test.Name)
+		}
+
+		reverted := readTestFeature(t, root, test_main_path)
+
+		// properties the geotag did not set are untouched and those it did are restored (or removed)
+
+		for _, k := range []string{"wof:depicts", "sfomuseum:bearing", "lbl:latitude", "lbl:longitude", "src:geom"} {
+
+			expected := gjson.GetBytes(record, "properties."+k)
+			rsp := gjson.GetBytes(reverted, "properties."+k)
+
+			if rsp.Exists() != expected.Exists() || !reflect.DeepEqual(rsp.Value(), expected.Value()) {
+				t.Errorf("%s: expected %s to be %v, got %v", test.Name, k, expected.Value(), rsp.Value())
+			}
+		}
+
+		gjson.GetBytes(reverted, "properties").ForEach(func(k gjson.Result, v gjson.Result) bool {
+
+			if wr.names.IsProperty(k.String()) {
+				t.Errorf("%s: unexpected geotag property %s", test.Name, k.String())
+			}
+
+			return true
+		})
+
+		// the pregeotag alt file is kept, so the original geometry is never lost, but the geotag is not
+
+		for _, label := range gjson.GetBytes(reverted, "properties.src:geom_alt").Array() {
+
+			if label.String() == "geotag-fov" {
+				t.Errorf("%s: expected geotag-fov to be removed from src:geom_alt", test.Name)
+			}
+		}
+
+		if !reflect.DeepEqual(gjson.GetBytes(reverted, "geometry").Value(), gjson.GetBytes(record, "geometry").Value()) {
+			t.Errorf("%s: expected the original geometry to be restored", test.Name)
+		}
+	}
+}
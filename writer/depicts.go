@@ -0,0 +1,57 @@
+package writer
+
+import (
+	"context"
+	"github.com/paulmach/orb"
+)
+
+// depicts returns the IDs of the places in the writer's spatial index that intersect the field of
+// view polygon fov, ranked by how much of the field of view they cover, and the ID of the most
+// specific place containing the target point (or -1 if there isn't one). The record being geotagged
+// (wof_id) is excluded from both.
+func (wr *WhosOnFirstGeotagWriter) depicts(ctx context.Context, wof_id int64, fov orb.Polygon, target orb.Point) ([]int64, int64, error) {
+
+	target_id := int64(-1)
+
+	containing, err := wr.spatial_index.PointInPolygon(ctx, target, wr.depicts_placetypes...)
+
+	if err != nil {
+		return nil, -1, err
+	}
+
+	for _, p := range containing {
+
+		if p.Id != wof_id {
+			target_id = p.Id
+			break
+		}
+	}
+
+	intersecting, err := wr.spatial_index.Intersects(ctx, fov, wr.depicts_placetypes...)
+
+	if err != nil {
+		return nil, -1, err
+	}
+
+	ids := make([]int64, 0)
+	has_target := false
+
+	for _, i := range intersecting {
+
+		if i.Place.Id == wof_id {
+			continue
+		}
+
+		if i.Place.Id == target_id {
+			has_target = true
+		}
+
+		ids = append(ids, i.Place.Id)
+	}
+
+	if target_id != -1 && !has_target {
+		ids = append(ids, target_id)
+	}
+
+	return ids, target_id, nil
+}
@@ -0,0 +1,29 @@
+package writer
+
+import (
+	"fmt"
+)
+
+// RefusedError is returned when WhosOnFirstGeotagWriter refuses to update a record because of the
+// record's current state.
+type RefusedError struct {
+	Id     int64  `json:"id"`
+	Reason string `json:"reason"`
+	// Successors are the IDs of the records that supersede the record, if it has been superseded.
+	Successors []int64 `json:"successors,omitempty"`
+}
+
+func (e *RefusedError) Error() string {
+	return fmt.Sprintf("Refusing to update %d: %s", e.Id, e.Reason)
+}
+
+// NotAllowedError is returned when a request asks for a per-request parameter value that the writer has
+// not been configured to allow.
+type NotAllowedError struct {
+	Parameter string `json:"parameter"`
+	Value     string `json:"value"`
+}
+
+func (e *NotAllowedError) Error() string {
+	return fmt.Sprintf("Value '%s' is not allowed for the %s parameter", e.Value, e.Parameter)
+}
@@ -0,0 +1,103 @@
+package writer
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+// PreconditionFailedError is returned when the entity tag supplied with a request does not match the
+// current entity tag of a geotag.
+type PreconditionFailedError struct {
+	Id   int64  `json:"id"`
+	ETag string `json:"etag"`
+}
+
+func (e *PreconditionFailedError) Error() string {
+	return fmt.Sprintf("Geotag for %d has been modified (current entity tag is %s)", e.Id, e.ETag)
+}
+
+// GeotagETag returns a (quoted) strong entity tag derived from the contents of the main record, the
+// alternate geometry being geotagged (if any) and the geotag alt file, in that order. Documents that
+// do not exist should be passed as nil.
+func GeotagETag(bodies ...[]byte) string {
+
+	h := sha256.New()
+
+	for _, body := range bodies {
+		sum := sha256.Sum256(body)
+		h.Write(sum[:])
+	}
+
+	return fmt.Sprintf("\"%x\"", h.Sum(nil))
+}
+
+// MatchETag returns true if the value of an "If-Match" header matches etag. An empty value always
+// matches and a value of "*" matches if the geotag exists.
+func MatchETag(if_match string, etag string, exists bool) bool {
+
+	if_match = strings.TrimSpace(if_match)
+
+	if if_match == "" {
+		return true
+	}
+
+	if if_match == "*" {
+		return exists
+	}
+
+	for _, candidate := range strings.Split(if_match, ",") {
+
+		if strings.TrimSpace(candidate) == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NoneMatchETag returns true if the value of an "If-None-Match" header does not match etag. An empty value
+// never matches and a value of "*" matches if the geotag exists.
+func NoneMatchETag(if_none_match string, etag string, exists bool) bool {
+
+	if_none_match = strings.TrimSpace(if_none_match)
+
+	if if_none_match == "" {
+		return true
+	}
+
+	if if_none_match == "*" {
+		return !exists
+	}
+
+	return !MatchETag(if_none_match, etag, exists)
+}
+
+// checkPreconditions ensures that the "If-Match" and "If-None-Match" values assigned to ctx allow the geotag
+// for wof_id, whose current entity tag is etag, to be written (or deleted). If not a *PreconditionFailedError
+// is returned.
+func checkPreconditions(ctx context.Context, wof_id int64, etag string, exists bool) error {
+
+	if_match, err := GetIfMatchFromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if_none_match, err := GetIfNoneMatchFromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if !MatchETag(if_match, etag, exists) || !NoneMatchETag(if_none_match, etag, exists) {
+
+		return &PreconditionFailedError{
+			Id:   wof_id,
+			ETag: etag,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package writer
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMatchETag(t *testing.T) {
+
+	etag := `"abc"`
+
+	tests := []struct {
+		IfMatch     string
+		Exists      bool
+		Match       bool
+		IfNoneMatch bool
+	}{
+		{"", true, true, true},
+		{"*", true, true, false},
+		{"*", false, false, true},
+		{`"abc"`, true, true, false},
+		{`"xyz", "abc"`, true, true, false},
+		{`"xyz"`, true, false, true},
+	}
+
+	for _, test := range tests {
+
+		if MatchETag(test.IfMatch, etag, test.Exists) != test.Match {
+			t.Errorf("Expected If-Match '%s' (exists %t) to be %t", test.IfMatch, test.Exists, test.Match)
+		}
+
+		if NoneMatchETag(test.IfMatch, etag, test.Exists) != test.IfNoneMatch {
+			t.Errorf("Expected If-None-Match '%s' (exists %t) to be %t", test.IfMatch, test.Exists, test.IfNoneMatch)
+		}
+	}
+}
+
+func TestGeotagETag(t *testing.T) {
+
+	main := []byte("main")
+	alt := []byte("alt")
+
+	if GeotagETag(main, nil, alt) != GeotagETag(main, nil, alt) {
+		t.Errorf("Expected entity tags to be stable")
+	}
+
+	// documents are not simply concatenated
+
+	if GeotagETag(main, nil, alt) == GeotagETag([]byte("mai"), nil, []byte("nalt")) {
+		t.Errorf("Expected different documents to have different entity tags")
+	}
+
+	if GeotagETag(main, nil, alt) == GeotagETag(main, alt, nil) {
+		t.Errorf("Expected the order of documents to matter")
+	}
+}
+
+func TestWriteFeaturePreconditions(t *testing.T) {
+
+	root, cleanup := newTestRoot(t)
+	defer cleanup()
+
+	writeTestFeature(t, root, test_main_path, readTestRecord(t))
+
+	wr := newTestWriter(t, root, nil)
+
+	// If-None-Match: * only allows the first geotag to be written
+
+	ctx, _ := SetIfNoneMatchWithContext(context.Background(), "*")
+
+	report, err := writeTestGeotagWithReport(ctx, t, wr)
+
+	if err != nil {
+		t.Fatalf("Failed to write first geotag, %v", err)
+	}
+
+	_, err = writeTestGeotagWithReport(ctx, t, wr)
+
+	_, ok := err.(*PreconditionFailedError)
+
+	if !ok {
+		t.Fatalf("Expected a *PreconditionFailedError for an existing geotag, got %v", err)
+	}
+
+	// the entity tag in the report is the current entity tag
+
+	ctx, _ = SetIfMatchWithContext(context.Background(), report.ETag)
+
+	_, err = writeTestGeotagWithReport(ctx, t, wr)
+
+	if err != nil {
+		t.Fatalf("Failed to write geotag with current entity tag, %v", err)
+	}
+
+	// the record has been modified since the entity tag was issued
+
+	record, err := setProperties(readTestFeature(t, root, test_main_path), map[string]interface{}{
+		"wof:name": "Modified",
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to modify record, %v", err)
+	}
+
+	writeTestFeature(t, root, test_main_path, record)
+
+	_, err = writeTestGeotagWithReport(ctx, t, wr)
+
+	precondition_err, ok := err.(*PreconditionFailedError)
+
+	if !ok {
+		t.Fatalf("Expected a *PreconditionFailedError for a stale entity tag, got %v", err)
+	}
+
+	if precondition_err.ETag == report.ETag {
+		t.Errorf("Expected the error to report the current entity tag")
+	}
+
+	err = wr.DeleteFeature(ctx, "1234567")
+
+	_, ok = err.(*PreconditionFailedError)
+
+	if !ok {
+		t.Fatalf("Expected a *PreconditionFailedError when deleting with a stale entity tag, got %v", err)
+	}
+
+	if readTestFeature(t, root, test_alt_path) == nil {
+		t.Errorf("Expected the geotag to be kept")
+	}
+}
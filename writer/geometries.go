@@ -0,0 +1,180 @@
+package writer
+
+import (
+	"context"
+	"fmt"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	wof_uri "github.com/whosonfirst/go-whosonfirst-uri"
+	"os"
+	"strings"
+)
+
+// The additional geometries that can be written as their own alt files alongside the field of view.
+const (
+	ALT_GEOMETRY_CAMERA  string = "camera"  // Point
+	ALT_GEOMETRY_TARGET  string = "target"  // Point
+	ALT_GEOMETRY_HORIZON string = "horizon" // LineString
+)
+
+func altGeometries() []string {
+
+	return []string{
+		ALT_GEOMETRY_CAMERA,
+		ALT_GEOMETRY_TARGET,
+		ALT_GEOMETRY_HORIZON,
+	}
+}
+
+// ParseAltGeometries parses a comma-separated list of additional geometries to write as alt files.
+func ParseAltGeometries(str_geometries string) ([]string, error) {
+
+	geometries := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, g := range strings.Split(str_geometries, ",") {
+
+		g = strings.TrimSpace(g)
+
+		switch g {
+		case ALT_GEOMETRY_CAMERA, ALT_GEOMETRY_TARGET, ALT_GEOMETRY_HORIZON:
+			// pass
+		default:
+			return nil, fmt.Errorf("Invalid alt geometry '%s'", g)
+		}
+
+		if seen[g] {
+			continue
+		}
+
+		seen[g] = true
+		geometries = append(geometries, g)
+	}
+
+	return geometries, nil
+}
+
+// stageAltGeometries returns a staged alt file for each of the writer's additional geometries (camera,
+// target and horizon) of the geotag named name for the record (or alternate geometry) described by uri_args,
+// along with their alt labels. props are assigned to each alt file, along with its spatial properties.
+func (wr *WhosOnFirstGeotagWriter) stageAltGeometries(ctx context.Context, wof_id int64, uri_args *wof_uri.URIArgs, name string, geotag_geom *geotagGeometry, props map[string]interface{}) ([]*stagedFeature, []string, error) {
+
+	staged := make([]*stagedFeature, 0)
+	labels := make([]string, 0)
+
+	for _, geometry := range wr.alt_geometries {
+
+		var geom orb.Geometry
+
+		switch geometry {
+		case ALT_GEOMETRY_CAMERA:
+			geom = geotag_geom.Camera
+		case ALT_GEOMETRY_TARGET:
+			geom = geotag_geom.Target
+		case ALT_GEOMETRY_HORIZON:
+			geom = orb.LineString{geotag_geom.Horizon[0], geotag_geom.Horizon[1]}
+		default:
+			return nil, nil, fmt.Errorf("Invalid alt geometry '%s'", geometry)
+		}
+
+		alt_label, err := wr.names.GeometryAltLabel(uri_args, name, geometry)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		alt_path, err := wof_uri.Id2RelPath(wof_id, wof_uri.NewAlternateURIArgs(alt_label, ""))
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		alt_props := map[string]interface{}{
+			"src:alt_label": alt_label,
+		}
+
+		for k, v := range props {
+			alt_props[k] = v
+		}
+
+		spatial_props, alt_bbox, err := SpatialProperties(geom)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for k, v := range spatial_props {
+			alt_props[k] = v
+		}
+
+		alt_feature := &WhosOnFirstAltFeature{
+			Type:       "Feature",
+			Id:         wof_id,
+			Properties: alt_props,
+			Bbox:       alt_bbox,
+			Geometry:   geojson.NewGeometry(geom),
+		}
+
+		alt_body, err := FormatAltFeature(alt_feature)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		staged = append(staged, &stagedFeature{
+			Role:     STAGED_GEOMETRY,
+			Path:     alt_path,
+			Body:     alt_body,
+			Previous: wr.readPrevious(ctx, alt_path),
+		})
+
+		labels = append(labels, alt_label)
+	}
+
+	return staged, labels, nil
+}
+
+// existingAltGeometries returns the staged removals of any additional geometry alt files (regardless of
+// whether the writer is currently configured to write them) of the geotag named name for the record (or
+// alternate geometry) described by uri_args, along with their alt labels.
+func (wr *WhosOnFirstGeotagWriter) existingAltGeometries(ctx context.Context, wof_id int64, uri_args *wof_uri.URIArgs, name string) ([]*stagedFeature, []string, error) {
+
+	staged := make([]*stagedFeature, 0)
+	labels := make([]string, 0)
+
+	for _, geometry := range altGeometries() {
+
+		alt_label, err := wr.names.GeometryAltLabel(uri_args, name, geometry)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		alt_path, err := wof_uri.Id2RelPath(wof_id, wof_uri.NewAlternateURIArgs(alt_label, ""))
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		alt_body, err := wr.readFeature(ctx, alt_path)
+
+		if err != nil {
+
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return nil, nil, err
+		}
+
+		staged = append(staged, &stagedFeature{
+			Role:     STAGED_GEOMETRY,
+			Path:     alt_path,
+			Previous: alt_body,
+		})
+
+		labels = append(labels, alt_label)
+	}
+
+	return staged, labels, nil
+}
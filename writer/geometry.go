@@ -0,0 +1,250 @@
+package writer
+
+import (
+	"fmt"
+	"github.com/paulmach/orb"
+	"github.com/sfomuseum/go-geojson-geotag"
+	"github.com/sfomuseum/go-www-geotag-whosonfirst/geo"
+	"github.com/tidwall/gjson"
+	"math"
+)
+
+const DEFAULT_BEARING_TOLERANCE float64 = 1.0   // degrees
+const DEFAULT_DISTANCE_TOLERANCE float64 = 0.01 // ratio
+
+const FOV_TRIANGLE string = "triangle"
+const FOV_ARC string = "arc"
+
+const DEFAULT_FOV_SEGMENTS int = 32
+
+// DistanceBand defines the optional minimum and maximum focus distances (in meters) of a geotag. Zero
+// values mean that the corresponding distance is not defined.
+type DistanceBand struct {
+	Min float64
+	Max float64
+}
+
+// DistanceBandFromFeatureBody returns the "distance_min" and "distance_max" properties of the encoded
+// geotag feature body (which may be nil).
+func DistanceBandFromFeatureBody(body []byte) (*DistanceBand, error) {
+
+	band := &DistanceBand{}
+
+	if body == nil {
+		return band, nil
+	}
+
+	for k, ptr := range map[string]*float64{
+		"properties.distance_min": &band.Min,
+		"properties.distance_max": &band.Max,
+	} {
+
+		rsp := gjson.GetBytes(body, k)
+
+		if !rsp.Exists() || rsp.Type == gjson.Null {
+			continue
+		}
+
+		if rsp.Type != gjson.Number {
+			return nil, &ValidationError{
+				Violations: []*Violation{
+					&Violation{Property: k, Message: "must be a number"},
+				},
+			}
+		}
+
+		*ptr = rsp.Float()
+	}
+
+	return band, nil
+}
+
+// geotagGeometry contains the values for a geotag feature computed on the WGS84 ellipsoid
+// alongside the bearing and distance values supplied by the client.
+type geotagGeometry struct {
+	Camera         orb.Point
+	Target         orb.Point
+	Horizon        [2]orb.Point
+	Angle          float64
+	Bearing        float64
+	Distance       float64
+	ClientBearing  float64
+	ClientDistance float64
+	DistanceMin    float64
+	DistanceMax    float64
+	// Disagreements is the list of properties ("bearing", "distance") whose client values differ from
+	// their computed values by more than the tolerances passed to newGeotagGeometry.
+	Disagreements []string
+}
+
+// newGeotagGeometry derives the target point (the geodesic midpoint of the horizon line) and the
+// bearing and distance from the point of view to the target for f.
+func newGeotagGeometry(f *geotag.GeotagFeature, band *DistanceBand, bearing_tolerance float64, distance_tolerance float64) (*geotagGeometry, error) {
+
+	pov, err := f.PointOfView()
+
+	if err != nil {
+		return nil, err
+	}
+
+	hl, err := f.HorizonLine()
+
+	if err != nil {
+		return nil, err
+	}
+
+	camera := orb.Point(pov.Coordinates)
+
+	horizon := [2]orb.Point{
+		orb.Point(hl.Coordinates[0]),
+		orb.Point(hl.Coordinates[1]),
+	}
+
+	props := f.Properties
+
+	var target orb.Point
+	var bearing float64
+	var distance float64
+
+	if props.Angle >= 180.0 {
+
+		// the midpoint of the horizon line is behind the camera (or is the
+		// camera) for angles of 180 degrees or more so trust the client
+
+		bearing = geo.NormalizeBearing(props.Bearing)
+		distance = props.Distance
+
+		target, err = geo.Direct(camera, bearing, distance)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to derive target, %v", err)
+		}
+
+	} else {
+
+		target, err = geo.Midpoint(horizon[0], horizon[1])
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to derive target, %v", err)
+		}
+
+		distance, bearing, _, err = geo.Inverse(camera, target)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to derive bearing and distance, %v", err)
+		}
+	}
+
+	g := &geotagGeometry{
+		Camera:         camera,
+		Target:         target,
+		Horizon:        horizon,
+		Angle:          props.Angle,
+		Bearing:        bearing,
+		Distance:       distance,
+		ClientBearing:  props.Bearing,
+		ClientDistance: props.Distance,
+		DistanceMin:    band.Min,
+		DistanceMax:    band.Max,
+		Disagreements:  make([]string, 0),
+	}
+
+	if math.Abs(geo.NormalizeBearing(g.Bearing-g.ClientBearing)) > bearing_tolerance {
+		g.Disagreements = append(g.Disagreements, "bearing")
+	}
+
+	if math.Abs(g.Distance-g.ClientDistance) > g.Distance*distance_tolerance {
+		g.Disagreements = append(g.Disagreements, "distance")
+	}
+
+	return g, nil
+}
+
+// FieldOfView returns the field of view polygon for g. If fov is FOV_TRIANGLE then the polygon is
+// the triangle formed by the camera and the horizon line. If fov is FOV_ARC then the polygon is a
+// circular sector whose far edge is a geodesic arc, at the distance to the target, approximated
+// by segments segments. Angles of 180 degrees or more are always rendered as arcs.
+//
+// If g has a minimum or maximum focus distance then the near and far edges of the polygon are moved
+// accordingly, producing a trapezoid (triangle) or an annular sector (arc).
+func (g *geotagGeometry) FieldOfView(fov string, segments int) (orb.Polygon, error) {
+
+	if g.Angle >= 180.0 {
+		fov = FOV_ARC
+	}
+
+	near := g.DistanceMin
+	far := g.Distance
+
+	if g.DistanceMax > 0.0 {
+		far = g.DistanceMax
+	}
+
+	switch fov {
+	case FOV_TRIANGLE:
+
+		if near == 0.0 && far == g.Distance {
+
+			ring := orb.Ring{
+				g.Camera,
+				g.Horizon[1], // right-hand rule
+				g.Horizon[0],
+				g.Camera,
+			}
+
+			return orb.Polygon{ring}, nil
+		}
+
+		// scale each edge of the triangle (camera to horizon) so that the
+		// near and far edges of the trapezoid remain parallel to the horizon
+
+		near_pts := make([]orb.Point, 2)
+		far_pts := make([]orb.Point, 2)
+
+		for i, pt := range g.Horizon {
+
+			d, b, _, err := geo.Inverse(g.Camera, pt)
+
+			if err != nil {
+				return nil, err
+			}
+
+			far_pts[i], err = geo.Direct(g.Camera, b, d*(far/g.Distance))
+
+			if err != nil {
+				return nil, err
+			}
+
+			near_pts[i], err = geo.Direct(g.Camera, b, d*(near/g.Distance))
+
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		ring := orb.Ring{
+			far_pts[1], // right-hand rule
+			far_pts[0],
+			near_pts[0],
+			near_pts[1],
+			far_pts[1],
+		}
+
+		if near == 0.0 {
+
+			ring = orb.Ring{
+				g.Camera,
+				far_pts[1],
+				far_pts[0],
+				g.Camera,
+			}
+		}
+
+		return orb.Polygon{ring}, nil
+
+	case FOV_ARC:
+		return geo.AnnularSector(g.Camera, g.Bearing, g.Angle, near, far, segments)
+	default:
+		return nil, fmt.Errorf("Invalid field of view type '%s'", fov)
+	}
+}
@@ -0,0 +1,84 @@
+package writer
+
+import (
+	"testing"
+)
+
+func TestDistanceBandFromFeatureBody(t *testing.T) {
+
+	tests := []struct {
+		Body    string
+		Band    DistanceBand
+		Invalid bool
+	}{
+		{`{"properties":{}}`, DistanceBand{}, false},
+		{`{"properties":{"distance_min":100,"distance_max":null}}`, DistanceBand{Min: 100.0}, false},
+		{`{"properties":{"distance_min":100,"distance_max":2000.5}}`, DistanceBand{Min: 100.0, Max: 2000.5}, false},
+		{`{"properties":{"distance_min":"100"}}`, DistanceBand{}, true},
+	}
+
+	for _, test := range tests {
+
+		band, err := DistanceBandFromFeatureBody([]byte(test.Body))
+
+		if test.Invalid {
+
+			_, ok := err.(*ValidationError)
+
+			if !ok {
+				t.Errorf("Expected %s to return a *ValidationError, got %v", test.Body, err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("Failed to derive distance band from %s, %v", test.Body, err)
+		}
+
+		if *band != test.Band {
+			t.Errorf("Expected %s to have band %v, got %v", test.Body, test.Band, *band)
+		}
+	}
+}
+
+func TestValidateDistanceBand(t *testing.T) {
+
+	opts := DefaultValidationOptions()
+	opts.MaxDistance = 5000.0
+
+	tests := []struct {
+		Name     string
+		Distance float64
+		Band     DistanceBand
+		Valid    bool
+	}{
+		{"No band", 1000.0, DistanceBand{}, true},
+		{"Near only", 1000.0, DistanceBand{Min: 500.0}, true},
+		{"Near and far", 1000.0, DistanceBand{Min: 500.0, Max: 4000.0}, true},
+		{"Near beyond computed distance", 1000.0, DistanceBand{Min: 1500.0}, false},
+		{"Near beyond far", 1000.0, DistanceBand{Min: 3000.0, Max: 2000.0}, false},
+		{"Negative near", 1000.0, DistanceBand{Min: -1.0}, false},
+		{"Far beyond maximum distance", 1000.0, DistanceBand{Max: 6000.0}, false},
+		{"Zero distance", 0.0, DistanceBand{}, false},
+	}
+
+	for _, test := range tests {
+
+		band := test.Band
+		err := ValidateDistanceBand(test.Distance, &band, opts)
+
+		if test.Valid && err != nil {
+			t.Errorf("%s: unexpected error, %v", test.Name, err)
+		}
+
+		if !test.Valid {
+
+			_, ok := err.(*ValidationError)
+
+			if !ok {
+				t.Errorf("%s: expected a *ValidationError, got %v", test.Name, err)
+			}
+		}
+	}
+}
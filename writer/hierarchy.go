@@ -0,0 +1,114 @@
+package writer
+
+import (
+	"context"
+	"fmt"
+	"github.com/paulmach/orb"
+	"github.com/sfomuseum/go-www-geotag-whosonfirst/index"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"log"
+)
+
+const HIERARCHY_WARN string = "warn"
+const HIERARCHY_REFUSE string = "refuse"
+
+// updateHierarchy assigns a new "wof:parent_id" and "wof:hierarchy" to the record body (whose ID is
+// wof_id) derived from the most specific place in the writer's spatial index containing pt. If none
+// of the places containing pt are part of the record's existing hierarchy then a warning is logged
+// or a *RefusedError is returned, depending on the writer's hierarchy policy.
+func (wr *WhosOnFirstGeotagWriter) updateHierarchy(ctx context.Context, wof_id int64, body []byte, pt orb.Point) ([]byte, error) {
+
+	placetype := gjson.GetBytes(body, "properties.wof:placetype").String()
+
+	results, err := wr.spatial_index.PointInPolygon(ctx, pt, wr.parent_placetypes...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]*index.Place, 0)
+
+	for _, p := range results {
+
+		if p.Id == wof_id {
+			continue
+		}
+
+		candidates = append(candidates, p)
+	}
+
+	existing := make(map[int64]bool)
+
+	for _, h := range index.Hierarchy(body) {
+
+		for _, id := range h {
+
+			if id > 0 && id != wof_id {
+				existing[id] = true
+			}
+		}
+	}
+
+	outside := len(existing) > 0
+
+	for _, p := range candidates {
+
+		if existing[p.Id] {
+			outside = false
+			break
+		}
+	}
+
+	if outside {
+
+		reason := fmt.Sprintf("new location (%f, %f) is outside the record's existing hierarchy", pt.Y(), pt.X())
+
+		switch wr.hierarchy_policy {
+		case HIERARCHY_REFUSE:
+			return nil, &RefusedError{Id: wof_id, Reason: reason}
+		default:
+			log.Printf("[WARNING] %d: %s", wof_id, reason)
+		}
+	}
+
+	if len(candidates) == 0 {
+		log.Printf("[WARNING] %d: no parent found for new location (%f, %f), leaving hierarchy untouched", wof_id, pt.Y(), pt.X())
+		return body, nil
+	}
+
+	parent := candidates[0]
+	pt_key := fmt.Sprintf("%s_id", placetype)
+
+	hierarchy := make([]map[string]int64, 0)
+
+	for _, parent_h := range parent.Hierarchy {
+
+		h := make(map[string]int64)
+
+		for k, v := range parent_h {
+			h[k] = v
+		}
+
+		h[pt_key] = wof_id
+		hierarchy = append(hierarchy, h)
+	}
+
+	if len(hierarchy) == 0 {
+
+		h := map[string]int64{
+			fmt.Sprintf("%s_id", parent.Placetype): parent.Id,
+			pt_key:                                 wof_id,
+		}
+
+		hierarchy = append(hierarchy, h)
+	}
+
+	body, err = sjson.SetBytes(body, "properties.wof:parent_id", parent.Id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sjson.SetBytes(body, "properties.wof:hierarchy", hierarchy)
+}
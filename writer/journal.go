@@ -0,0 +1,227 @@
+package writer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	wof_uri "github.com/whosonfirst/go-whosonfirst-uri"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const JOURNAL_ROLLBACK string = "rollback"
+const JOURNAL_REPLAY string = "replay"
+
+const JOURNAL_EXTENSION string = ".json"
+
+// Recoverer is implemented by writers that can recover from incomplete writes.
+type Recoverer interface {
+	Recover(context.Context) error
+}
+
+// journalEntry is the document written to the journal directory before any staged documents are
+// written. It is removed once all the documents have been written (or rolled back).
+type journalEntry struct {
+	Created   int64            `json:"created"`
+	Documents []*stagedFeature `json:"documents"`
+}
+
+// journalBegin records staged in the writer's journal directory and returns the path of the journal
+// entry or an empty string if the writer does not have a journal.
+func (wr *WhosOnFirstGeotagWriter) journalBegin(ctx context.Context, staged []*stagedFeature) (string, error) {
+
+	if wr.journal == "" {
+		return "", nil
+	}
+
+	now := time.Now()
+
+	entry := &journalEntry{
+		Created:   now.Unix(),
+		Documents: staged,
+	}
+
+	body, err := json.Marshal(entry)
+
+	if err != nil {
+		return "", err
+	}
+
+	// journal entries are written to a temporary file and then renamed so that a partial
+	// entry is never mistaken for a complete one
+
+	tmp_file, err := ioutil.TempFile(wr.journal, ".entry-")
+
+	if err != nil {
+		return "", err
+	}
+
+	tmp_path := tmp_file.Name()
+
+	_, err = tmp_file.Write(body)
+
+	if err == nil {
+		err = tmp_file.Sync()
+	}
+
+	close_err := tmp_file.Close()
+
+	if err == nil {
+		err = close_err
+	}
+
+	if err != nil {
+		os.Remove(tmp_path)
+		return "", err
+	}
+
+	fname := fmt.Sprintf("%d-%s%s", now.UnixNano(), strings.TrimPrefix(filepath.Base(tmp_path), ".entry-"), JOURNAL_EXTENSION)
+	path := filepath.Join(wr.journal, fname)
+
+	err = os.Rename(tmp_path, path)
+
+	if err != nil {
+		os.Remove(tmp_path)
+		return "", err
+	}
+
+	return path, nil
+}
+
+// journalEnd marks the journal entry at path as complete by removing it.
+func (wr *WhosOnFirstGeotagWriter) journalEnd(ctx context.Context, path string) error {
+
+	if path == "" {
+		return nil
+	}
+
+	return os.Remove(path)
+}
+
+// Recover replays or rolls back, depending on the writer's journal recovery policy, any incomplete
+// writes recorded in the writer's journal directory, oldest first. Entries are removed once they have
+// been recovered. If the writer does not have a journal this method does nothing.
+func (wr *WhosOnFirstGeotagWriter) Recover(ctx context.Context) error {
+
+	if wr.journal == "" {
+		return nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(wr.journal, "*"+JOURNAL_EXTENSION))
+
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(matches)
+
+	for _, path := range matches {
+
+		err := wr.recoverEntry(ctx, path)
+
+		if err != nil {
+			return fmt.Errorf("Failed to recover journal entry %s, %v", path, err)
+		}
+
+		log.Printf("Recovered journal entry %s (%s)", path, wr.journal_recovery)
+	}
+
+	return nil
+}
+
+func (wr *WhosOnFirstGeotagWriter) recoverEntry(ctx context.Context, path string) error {
+
+	body, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return err
+	}
+
+	var entry *journalEntry
+
+	err = json.Unmarshal(body, &entry)
+
+	if err != nil {
+		return err
+	}
+
+	if len(entry.Documents) == 0 {
+		return wr.journalEnd(ctx, path)
+	}
+
+	wof_id, _, err := wof_uri.ParseURI(filepath.Base(entry.Documents[0].Path))
+
+	if err != nil {
+		return err
+	}
+
+	rel_path, err := wof_uri.Id2RelPath(wof_id)
+
+	if err != nil {
+		return err
+	}
+
+	unlock, err := wr.lock(ctx, wof_id, rel_path)
+
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	// the write may have completed while we were waiting for the lock
+
+	_, err = os.Stat(path)
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	switch wr.journal_recovery {
+	case JOURNAL_REPLAY:
+
+		for _, f := range entry.Documents {
+
+			if f.Body != nil {
+				err = wr.writeFeature(ctx, f.Path, f.Body)
+			} else {
+				err = wr.removeFeature(ctx, f.Path)
+			}
+
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+
+	default:
+
+		for i := len(entry.Documents) - 1; i >= 0; i-- {
+
+			f := entry.Documents[i]
+
+			if f.Previous == nil {
+
+				err := wr.removeFeature(ctx, f.Path)
+
+				if err != nil && !os.IsNotExist(err) {
+					return err
+				}
+
+				continue
+			}
+
+			err := wr.writeFeature(ctx, f.Path, f.Previous)
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return wr.journalEnd(ctx, path)
+}
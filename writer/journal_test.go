@@ -0,0 +1,107 @@
+package writer
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+
+	tests := []struct {
+		Name     string
+		Recovery string
+		Staged   []*stagedFeature
+		Expected map[string][]byte
+	}{
+		{
+			Name:     "Rollback",
+			Recovery: JOURNAL_ROLLBACK,
+			Staged: []*stagedFeature{
+				{Role: STAGED_ALT, Path: test_alt_path, Body: []byte("alt")},
+				{Role: STAGED_MAIN, Path: test_main_path, Body: []byte("main v2"), Previous: []byte("main")},
+			},
+			Expected: map[string][]byte{
+				test_alt_path:  nil,
+				test_main_path: []byte("main"),
+			},
+		},
+		{
+			Name:     "Replay",
+			Recovery: JOURNAL_REPLAY,
+			Staged: []*stagedFeature{
+				{Role: STAGED_ALT, Path: test_alt_path, Body: []byte("alt")},
+				{Role: STAGED_MAIN, Path: test_main_path, Body: []byte("main v2"), Previous: []byte("main")},
+			},
+			Expected: map[string][]byte{
+				test_alt_path:  []byte("alt"),
+				test_main_path: []byte("main v2"),
+			},
+		},
+		{
+			Name:     "Empty",
+			Recovery: JOURNAL_ROLLBACK,
+			Staged:   []*stagedFeature{},
+			Expected: map[string][]byte{
+				test_main_path: []byte("main"),
+			},
+		},
+	}
+
+	for _, test := range tests {
+
+		root, cleanup := newTestRoot(t)
+		defer cleanup()
+
+		journal := filepath.Join(root, "journal")
+
+		q := url.Values{}
+		q.Set("journal", journal)
+		q.Set("journal_recovery", test.Recovery)
+
+		wr := newTestWriter(t, root, q)
+
+		ctx := context.Background()
+
+		writeTestFeature(t, root, test_main_path, []byte("main"))
+
+		// simulate a crash after the journal entry and the first document have been written
+
+		_, err := wr.journalBegin(ctx, test.Staged)
+
+		if err != nil {
+			t.Fatalf("%s: failed to begin journal entry, %v", test.Name, err)
+		}
+
+		if len(test.Staged) > 0 {
+			writeTestFeature(t, root, test.Staged[0].Path, test.Staged[0].Body)
+		}
+
+		err = wr.Recover(ctx)
+
+		if err != nil {
+			t.Fatalf("%s: failed to recover, %v", test.Name, err)
+		}
+
+		for path, expected := range test.Expected {
+
+			body := readTestFeature(t, root, path)
+
+			if !bytes.Equal(body, expected) {
+				t.Errorf("%s: expected %s to be '%s', got '%s'", test.Name, path, expected, body)
+			}
+		}
+
+		matches, err := filepath.Glob(filepath.Join(journal, "*"+JOURNAL_EXTENSION))
+
+		if err != nil {
+			t.Fatalf("%s: failed to list journal entries, %v", test.Name, err)
+		}
+
+		if len(matches) != 0 {
+			t.Errorf("%s: expected the journal to be empty, found %v", test.Name, matches)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package writer
+
+import (
+	"context"
+	"fmt"
+	"github.com/tidwall/gjson"
+	wof_uri "github.com/whosonfirst/go-whosonfirst-uri"
+)
+
+const SUPERSEDED_REFUSE string = "refuse"
+const SUPERSEDED_FOLLOW string = "follow"
+
+const LIFECYCLE_CURRENT string = "current"
+const LIFECYCLE_DEPRECATED string = "deprecated"
+const LIFECYCLE_CEASED string = "ceased"
+const LIFECYCLE_SUPERSEDED string = "superseded"
+
+const DECISION_ACCEPTED string = "accepted"
+const DECISION_FOLLOWED string = "followed"
+const DECISION_OVERRIDDEN string = "overridden"
+
+// MAX_SUPERSEDED_DEPTH is the maximum number of "wof:superseded_by" relations that will be followed.
+const MAX_SUPERSEDED_DEPTH int = 10
+
+// LifecycleDecision describes how WhosOnFirstGeotagWriter handled the lifecycle state of the record
+// being geotagged.
+type LifecycleDecision struct {
+	// RequestedId is the ID of the record that was requested.
+	RequestedId int64 `json:"requested_id"`
+	// Id is the ID of the record that was geotagged.
+	Id int64 `json:"id"`
+	// State is the lifecycle state of the requested record.
+	State string `json:"state"`
+	// Decision is one of DECISION_ACCEPTED, DECISION_FOLLOWED or DECISION_OVERRIDDEN.
+	Decision string `json:"decision"`
+	// SupersededBy is the chain of records that were followed to reach Id, if any.
+	SupersededBy []int64 `json:"superseded_by,omitempty"`
+}
+
+// LifecycleState returns the lifecycle state of the record body. Superseded records take precedence
+// over deprecated records which take precedence over ceased records.
+func LifecycleState(body []byte) string {
+
+	if len(supersededBy(body)) > 0 {
+		return LIFECYCLE_SUPERSEDED
+	}
+
+	if isKnownDate(gjson.GetBytes(body, "properties.edtf:deprecated")) {
+		return LIFECYCLE_DEPRECATED
+	}
+
+	if isKnownDate(gjson.GetBytes(body, "properties.edtf:cessation")) {
+		return LIFECYCLE_CEASED
+	}
+
+	return LIFECYCLE_CURRENT
+}
+
+// resolveLifecycle checks the lifecycle state of the record body (whose ID is wof_id) following any
+// "wof:superseded_by" relations, if the writer's superseded policy allows it. It returns the ID, path and
+// body of the record to geotag and a LifecycleDecision, or a *RefusedError if the record should not be
+// geotagged. Deprecated and ceased records are refused unless override is true.
+func (wr *WhosOnFirstGeotagWriter) resolveLifecycle(ctx context.Context, wof_id int64, body []byte, is_alternate bool, override bool) (int64, string, []byte, *LifecycleDecision, error) {
+
+	decision := &LifecycleDecision{
+		RequestedId:  wof_id,
+		Id:           wof_id,
+		State:        LifecycleState(body),
+		Decision:     DECISION_ACCEPTED,
+		SupersededBy: make([]int64, 0),
+	}
+
+	successors := supersededBy(body)
+
+	if len(successors) > 0 {
+
+		if wr.superseded_policy != SUPERSEDED_FOLLOW {
+
+			return 0, "", nil, nil, &RefusedError{
+				Id:         wof_id,
+				Reason:     "Record has been superseded",
+				Successors: successors,
+			}
+		}
+
+		followed, err := FollowSuperseded(ctx, wof_id, body, is_alternate, wr.readFeature)
+
+		if err != nil {
+			return 0, "", nil, nil, err
+		}
+
+		wof_id = followed.Id
+		body = followed.Body
+
+		decision.Id = wof_id
+		decision.Decision = DECISION_FOLLOWED
+		decision.SupersededBy = followed.SupersededBy
+	}
+
+	err := checkLifecycle(wof_id, body, override, decision)
+
+	if err != nil {
+		return 0, "", nil, nil, err
+	}
+
+	rel_path, err := wof_uri.Id2RelPath(wof_id)
+
+	if err != nil {
+		return 0, "", nil, nil, err
+	}
+
+	return wof_id, rel_path, body, decision, nil
+}
+
+// resolveLockedLifecycle calls resolveLifecycle for the record body (whose ID is wof_id), which the caller
+// must already have locked. If a superseding record is followed then it is locked and read again, and its
+// lifecycle state checked again, in case it was changed while waiting for the lock. In addition to the values
+// returned by resolveLifecycle it returns a function to release the superseding record's lock, which does
+// nothing if no record was followed.
+func (wr *WhosOnFirstGeotagWriter) resolveLockedLifecycle(ctx context.Context, wof_id int64, body []byte, is_alternate bool, override bool) (int64, string, []byte, *LifecycleDecision, func(), error) {
+
+	unlock := func() {}
+
+	wof_id, rel_path, body, decision, err := wr.resolveLifecycle(ctx, wof_id, body, is_alternate, override)
+
+	if err != nil {
+		return 0, "", nil, nil, unlock, err
+	}
+
+	if decision.Id == decision.RequestedId {
+		return wof_id, rel_path, body, decision, unlock, nil
+	}
+
+	unlock, err = wr.lock(ctx, wof_id, rel_path)
+
+	if err != nil {
+		return 0, "", nil, nil, func() {}, err
+	}
+
+	body, err = wr.readFeature(ctx, rel_path)
+
+	if err == nil {
+		err = checkLifecycle(wof_id, body, override, decision)
+	}
+
+	if err != nil {
+		unlock()
+		return 0, "", nil, nil, func() {}, err
+	}
+
+	return wof_id, rel_path, body, decision, unlock, nil
+}
+
+// checkLifecycle returns a *RefusedError if the record body (whose ID is wof_id) has been superseded or if it
+// is deprecated or ceased and override is false. Overridden records are recorded in decision.
+func checkLifecycle(wof_id int64, body []byte, override bool, decision *LifecycleDecision) error {
+
+	successors := supersededBy(body)
+
+	if len(successors) > 0 {
+
+		return &RefusedError{
+			Id:         wof_id,
+			Reason:     "Record has been superseded",
+			Successors: successors,
+		}
+	}
+
+	state := LifecycleState(body)
+
+	switch state {
+	case LIFECYCLE_DEPRECATED, LIFECYCLE_CEASED:
+
+		if !override {
+
+			return &RefusedError{
+				Id:     wof_id,
+				Reason: fmt.Sprintf("Record is %s", state),
+			}
+		}
+
+		decision.Decision = DECISION_OVERRIDDEN
+	}
+
+	return nil
+}
+
+// SupersededRecord is the record that (ultimately) supersedes another record.
+type SupersededRecord struct {
+	// Id is the ID of the superseding record.
+	Id int64
+	// Body is the body of the superseding record.
+	Body []byte
+	// SupersededBy is the chain of records that were followed to reach Id.
+	SupersededBy []int64
+}
+
+// FollowSuperseded follows the "wof:superseded_by" relations of the record body (whose ID is wof_id),
+// reading each superseding record with read, and returns the record that is not itself superseded. If body
+// is not superseded then it is returned as is. A *RefusedError is returned if the relations can not be
+// followed: because body is an alternate geometry (is_alternate is true), a record is superseded by more
+// than one record or the relations are circular or deeper than MAX_SUPERSEDED_DEPTH.
+func FollowSuperseded(ctx context.Context, wof_id int64, body []byte, is_alternate bool, read func(context.Context, string) ([]byte, error)) (*SupersededRecord, error) {
+
+	followed := &SupersededRecord{
+		Id:           wof_id,
+		Body:         body,
+		SupersededBy: make([]int64, 0),
+	}
+
+	seen := map[int64]bool{
+		wof_id: true,
+	}
+
+	for {
+
+		successors := supersededBy(followed.Body)
+
+		if len(successors) == 0 {
+			break
+		}
+
+		refused := &RefusedError{
+			Id:         followed.Id,
+			Reason:     "Record has been superseded",
+			Successors: successors,
+		}
+
+		if is_alternate {
+			refused.Reason = "Record has been superseded and alternate geometries can not be followed"
+			return nil, refused
+		}
+
+		if len(successors) > 1 {
+			refused.Reason = "Record has been superseded by more than one record"
+			return nil, refused
+		}
+
+		next_id := successors[0]
+
+		if seen[next_id] || len(followed.SupersededBy) >= MAX_SUPERSEDED_DEPTH {
+			refused.Reason = "Unable to resolve the records that supersede this record"
+			return nil, refused
+		}
+
+		seen[next_id] = true
+
+		next_path, err := wof_uri.Id2RelPath(next_id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		next_body, err := read(ctx, next_path)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read superseding record %d, %v", next_id, err)
+		}
+
+		followed.Id = next_id
+		followed.Body = next_body
+		followed.SupersededBy = append(followed.SupersededBy, next_id)
+	}
+
+	return followed, nil
+}
+
+func supersededBy(body []byte) []int64 {
+
+	ids := make([]int64, 0)
+
+	for _, r := range gjson.GetBytes(body, "properties.wof:superseded_by").Array() {
+
+		id := r.Int()
+
+		if id > 0 {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// isKnownDate returns true if r is an EDTF date that is neither empty, unknown nor open.
+func isKnownDate(r gjson.Result) bool {
+
+	if !r.Exists() {
+		return false
+	}
+
+	switch r.String() {
+	case "", "uuuu", "..", "open":
+		return false
+	default:
+		return true
+	}
+}
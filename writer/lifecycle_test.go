@@ -0,0 +1,247 @@
+package writer
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+)
+
+const test_successor_path string = "765/432/1/7654321.geojson"
+const test_successor_alt_path string = "765/432/1/7654321-alt-geotag-fov.geojson"
+
+func TestLifecycleState(t *testing.T) {
+
+	tests := map[string]string{
+		`{"properties":{}}`: LIFECYCLE_CURRENT,
+		`{"properties":{"edtf:deprecated":"uuuu","edtf:cessation":""}}`:                 LIFECYCLE_CURRENT,
+		`{"properties":{"edtf:cessation":"2019-01-01"}}`:                                LIFECYCLE_CEASED,
+		`{"properties":{"edtf:deprecated":"2020-01-01","edtf:cessation":".."}}`:         LIFECYCLE_DEPRECATED,
+		`{"properties":{"edtf:deprecated":"2020-01-01","wof:superseded_by":[7654321]}}`: LIFECYCLE_SUPERSEDED,
+	}
+
+	for body, expected := range tests {
+
+		state := LifecycleState([]byte(body))
+
+		if state != expected {
+			t.Errorf("Expected %s to be %s, got %s", body, expected, state)
+		}
+	}
+}
+
+func TestFollowSuperseded(t *testing.T) {
+
+	records := map[string]string{
+		"765/432/1/7654321.geojson": `{"properties":{"wof:superseded_by":[1111111]}}`,
+		"111/111/1/1111111.geojson": `{"properties":{}}`,
+		"222/222/2/2222222.geojson": `{"properties":{"wof:superseded_by":[3333333]}}`,
+		"333/333/3/3333333.geojson": `{"properties":{"wof:superseded_by":[2222222]}}`,
+	}
+
+	read := func(ctx context.Context, path string) ([]byte, error) {
+
+		body, ok := records[path]
+
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+
+		return []byte(body), nil
+	}
+
+	tests := []struct {
+		Name         string
+		Body         string
+		IsAlternate  bool
+		Id           int64
+		SupersededBy []int64
+		Refused      bool
+	}{
+		{"Not superseded", `{"properties":{}}`, false, 1234567, []int64{}, false},
+		{"Chain", `{"properties":{"wof:superseded_by":[7654321]}}`, false, 1111111, []int64{7654321, 1111111}, false},
+		{"Alternate geometry", `{"properties":{"wof:superseded_by":[7654321]}}`, true, 0, nil, true},
+		{"More than one successor", `{"properties":{"wof:superseded_by":[7654321,1111111]}}`, false, 0, nil, true},
+		{"Circular", `{"properties":{"wof:superseded_by":[2222222]}}`, false, 0, nil, true},
+	}
+
+	for _, test := range tests {
+
+		followed, err := FollowSuperseded(context.Background(), 1234567, []byte(test.Body), test.IsAlternate, read)
+
+		if test.Refused {
+
+			_, ok := err.(*RefusedError)
+
+			if !ok {
+				t.Errorf("%s: expected a *RefusedError, got %v", test.Name, err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%s: failed to follow superseded records, %v", test.Name, err)
+		}
+
+		if followed.Id != test.Id || !reflect.DeepEqual(followed.SupersededBy, test.SupersededBy) {
+			t.Errorf("%s: expected %d (%v), got %d (%v)", test.Name, test.Id, test.SupersededBy, followed.Id, followed.SupersededBy)
+		}
+	}
+}
+
+func TestCheckLifecycle(t *testing.T) {
+
+	tests := []struct {
+		Body     string
+		Override bool
+		Decision string
+		Refused  bool
+	}{
+		{`{"properties":{}}`, false, DECISION_ACCEPTED, false},
+		{`{"properties":{"edtf:deprecated":"2020-01-01"}}`, false, "", true},
+		{`{"properties":{"edtf:deprecated":"2020-01-01"}}`, true, DECISION_OVERRIDDEN, false},
+		// a superseding record that was itself superseded while waiting for its lock
+		{`{"properties":{"wof:superseded_by":[1111111]}}`, true, "", true},
+	}
+
+	for _, test := range tests {
+
+		decision := &LifecycleDecision{
+			Decision: DECISION_ACCEPTED,
+		}
+
+		err := checkLifecycle(7654321, []byte(test.Body), test.Override, decision)
+
+		if test.Refused {
+
+			_, ok := err.(*RefusedError)
+
+			if !ok {
+				t.Errorf("Expected %s to be refused, got %v", test.Body, err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected error for %s, %v", test.Body, err)
+			continue
+		}
+
+		if decision.Decision != test.Decision {
+			t.Errorf("Expected %s to be %s, got %s", test.Body, test.Decision, decision.Decision)
+		}
+	}
+}
+
+func TestDeleteFeatureSuperseded(t *testing.T) {
+
+	root, cleanup := newTestRoot(t)
+	defer cleanup()
+
+	successor, err := setProperties(readTestRecord(t), map[string]interface{}{
+		"wof:id":         7654321,
+		"wof:supersedes": []int64{1234567},
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to create superseding record, %v", err)
+	}
+
+	writeTestFeature(t, root, test_successor_path, successor)
+
+	record, err := setProperties(readTestRecord(t), map[string]interface{}{
+		"wof:superseded_by": []int64{7654321},
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to create superseded record, %v", err)
+	}
+
+	writeTestFeature(t, root, test_main_path, record)
+
+	wr := newTestWriter(t, root, url.Values{"superseded": []string{SUPERSEDED_FOLLOW}})
+
+	ctx := context.Background()
+
+	writeTestGeotag(t, ctx, wr)
+
+	if readTestFeature(t, root, test_successor_alt_path) == nil {
+		t.Fatalf("Expected the superseding record to be geotagged")
+	}
+
+	err = wr.DeleteFeature(ctx, "1234567")
+
+	if err != nil {
+		t.Fatalf("Failed to delete geotag, %v", err)
+	}
+
+	if readTestFeature(t, root, test_successor_alt_path) != nil {
+		t.Errorf("Expected the geotag of the superseding record to be deleted")
+	}
+}
+
+func TestDeleteFeatureDeprecated(t *testing.T) {
+
+	tests := []struct {
+		Name          string
+		AllowOverride string
+		Override      string
+		Refused       bool
+	}{
+		{"No override", "0", "", true},
+		{"Override not allowed", "0", "1", true},
+		{"Override", "1", "1", false},
+	}
+
+	for _, test := range tests {
+
+		root, cleanup := newTestRoot(t)
+		defer cleanup()
+
+		writeTestFeature(t, root, test_main_path, readTestRecord(t))
+
+		wr := newTestWriter(t, root, url.Values{"allow_override": []string{test.AllowOverride}})
+
+		writeTestGeotag(t, context.Background(), wr)
+
+		// the record is deprecated after it was geotagged
+
+		record, err := setProperties(readTestFeature(t, root, test_main_path), map[string]interface{}{
+			"edtf:deprecated": "2020-01-01",
+		})
+
+		if err != nil {
+			t.Fatalf("%s: failed to deprecate record, %v", test.Name, err)
+		}
+
+		writeTestFeature(t, root, test_main_path, record)
+
+		ctx, _ := SetRequestParametersWithContext(context.Background(), url.Values{"override": []string{test.Override}})
+
+		err = wr.DeleteFeature(ctx, "1234567")
+
+		if test.Refused {
+
+			if err == nil {
+				t.Errorf("%s: expected delete to be refused", test.Name)
+			}
+
+			if readTestFeature(t, root, test_alt_path) == nil {
+				t.Errorf("%s: expected the geotag to be kept", test.Name)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%s: failed to delete geotag, %v", test.Name, err)
+		}
+
+		if readTestFeature(t, root, test_alt_path) != nil {
+			t.Errorf("%s: expected the geotag to be deleted", test.Name)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package writer
+
+import (
+	"context"
+	"github.com/sfomuseum/go-www-geotag-whosonfirst/lock"
+	"log"
+	"time"
+)
+
+const DEFAULT_LOCK_TIMEOUT time.Duration = 10 * time.Second
+const DEFAULT_LOCK_STALE time.Duration = 5 * time.Minute
+
+// lock acquires the in-process lock for wof_id and, if the writer has lockfiles enabled, the lockfile for
+// the record at rel_path (in the writer's lock directory, if defined, or next to the record). It returns a function that must be called to release the lock(s). If the lock(s)
+// can not be acquired before the writer's lock timeout a *RefusedError is returned.
+func (wr *WhosOnFirstGeotagWriter) lock(ctx context.Context, wof_id int64, rel_path string) (func(), error) {
+
+	lock_ctx, cancel := context.WithTimeout(ctx, wr.lock_timeout)
+	defer cancel()
+
+	unlock_id, err := wr.locks.Lock(lock_ctx, wof_id)
+
+	if err != nil {
+		return nil, lockError(wof_id, err)
+	}
+
+	if !wr.lockfile {
+		return unlock_id, nil
+	}
+
+	lockfile_path := lock.LockfilePath(wr.writer.URI(rel_path), wof_id)
+
+	if wr.lock_dir != "" {
+		lockfile_path = lock.LockfileDirectoryPath(wr.lock_dir, wof_id)
+	}
+
+	unlock_file, err := lock.AcquireLockfile(lock_ctx, lockfile_path, wof_id, wr.lock_stale)
+
+	if err != nil {
+		unlock_id()
+		return nil, lockError(wof_id, err)
+	}
+
+	unlock := func() {
+
+		err := unlock_file()
+
+		if err != nil {
+			log.Printf("[WARNING] Failed to remove lockfile %s, %v", lockfile_path, err)
+		}
+
+		unlock_id()
+	}
+
+	return unlock, nil
+}
+
+func lockError(wof_id int64, err error) error {
+
+	switch err.(type) {
+	case *lock.LockTimeoutError:
+
+		return &RefusedError{
+			Id:     wof_id,
+			Reason: "Record is locked by another writer",
+		}
+
+	default:
+		return err
+	}
+}
@@ -0,0 +1,229 @@
+package writer
+
+import (
+	"errors"
+	"fmt"
+	wof_uri "github.com/whosonfirst/go-whosonfirst-uri"
+	"regexp"
+	"strings"
+)
+
+var re_label *regexp.Regexp
+var re_namespace *regexp.Regexp
+
+// reserved_namespaces are the Who's On First property namespaces that can not be used for geotag properties.
+var reserved_namespaces map[string]bool
+
+func init() {
+
+	// alt labels are "{SOURCE}-{FUNCTION}" so that geotag names can be appended as extras
+
+	re_label = regexp.MustCompile(`^[a-zA-Z0-9_]+\-[a-zA-Z0-9_]+$`)
+	re_namespace = regexp.MustCompile(`^[a-z0-9_]+:[a-z0-9_]*$`)
+
+	reserved_namespaces = map[string]bool{
+		"edtf": true,
+		"geom": true,
+		"lbl":  true,
+		"mz":   true,
+		"name": true,
+		"src":  true,
+		"wof":  true,
+	}
+}
+
+// GeotagNames defines the alt label of geotag alt files and the prefix of the properties that
+// WhosOnFirstGeotagWriter assigns to them (and to the records they geotag).
+type GeotagNames struct {
+	// Label is the alt label of geotag alt files, for example "geotag-fov".
+	Label string
+	// Namespace is the prefix of geotag properties, for example "geotag:" or "sfomuseum:geotag_".
+	Namespace string
+}
+
+// DefaultGeotagNames returns the GeotagNames used when none are configured.
+func DefaultGeotagNames() *GeotagNames {
+
+	n := &GeotagNames{
+		Label:     GEOTAG_LABEL,
+		Namespace: fmt.Sprintf("%s:", GEOTAG_NS),
+	}
+
+	return n
+}
+
+// NewGeotagNames returns a GeotagNames instance for label and namespace, either of which may be empty
+// in which case the default value is used. Labels must be valid Who's On First alt labels of the form
+// "{SOURCE}-{FUNCTION}" and namespaces must be a property prefix ("{NAMESPACE}:" optionally followed
+// by a string) or a namespace (in which case a trailing ":" is added).
+func NewGeotagNames(label string, namespace string) (*GeotagNames, error) {
+
+	n := DefaultGeotagNames()
+
+	if label != "" {
+
+		if !re_label.MatchString(label) {
+			return nil, fmt.Errorf("Invalid label '%s', labels must be of the form {SOURCE}-{FUNCTION}", label)
+		}
+
+		function := strings.Split(label, "-")[1]
+
+		for _, reserved := range append(altGeometries(), PREGEOTAG_FUNCTION) {
+
+			if function == reserved {
+				return nil, fmt.Errorf("Invalid label '%s', the %s function is reserved", label, reserved)
+			}
+		}
+
+		n.Label = label
+	}
+
+	if namespace != "" {
+
+		if !strings.Contains(namespace, ":") {
+			namespace = fmt.Sprintf("%s:", namespace)
+		}
+
+		if !re_namespace.MatchString(namespace) {
+			return nil, fmt.Errorf("Invalid namespace '%s'", namespace)
+		}
+
+		ns := strings.Split(namespace, ":")[0]
+
+		if reserved_namespaces[ns] {
+			return nil, fmt.Errorf("Invalid namespace '%s', the %s namespace is reserved", namespace, ns)
+		}
+
+		n.Namespace = namespace
+	}
+
+	return n, nil
+}
+
+// Property returns the namespaced name of the geotag property name, for example "geotag:angle".
+func (n *GeotagNames) Property(name string) string {
+	return fmt.Sprintf("%s%s", n.Namespace, name)
+}
+
+// IsProperty returns true if k is a geotag property.
+func (n *GeotagNames) IsProperty(k string) bool {
+	return strings.HasPrefix(k, n.Namespace)
+}
+
+// AltLabel returns the alt label for the geotag named name (which may be empty) of the record (or
+// alternate geometry) described by uri_args.
+func (n *GeotagNames) AltLabel(uri_args *wof_uri.URIArgs, name string) (string, error) {
+	return n.altLabel(uri_args, name, n.Label)
+}
+
+// GeometryAltLabel returns the alt label for the additional geometry (one of ALT_GEOMETRY_CAMERA,
+// ALT_GEOMETRY_TARGET or ALT_GEOMETRY_HORIZON) of the geotag named name (which may be empty) of the
+// record (or alternate geometry) described by uri_args. The label uses the same source as the geotag's
+// alt label, for example "geotag-camera".
+func (n *GeotagNames) GeometryAltLabel(uri_args *wof_uri.URIArgs, name string, geometry string) (string, error) {
+	return n.altLabel(uri_args, name, n.geometryLabel(geometry))
+}
+
+func (n *GeotagNames) geometryLabel(geometry string) string {
+	source := strings.Split(n.Label, "-")[0]
+	return fmt.Sprintf("%s-%s", source, geometry)
+}
+
+func (n *GeotagNames) altLabel(uri_args *wof_uri.URIArgs, name string, label string) (string, error) {
+
+	alt_geom := &wof_uri.AltGeom{
+		Source: label,
+	}
+
+	if uri_args.IsAlternate {
+
+		alt_label, err := uri_args.AltGeom.String()
+
+		if err != nil {
+			return "", err
+		}
+
+		labels := []string{
+			n.Label,
+		}
+
+		for _, geometry := range altGeometries() {
+			labels = append(labels, n.geometryLabel(geometry))
+		}
+
+		for _, l := range labels {
+
+			if strings.Contains(alt_label, l) {
+				return "", errors.New("Geotagging geotag alt files is not supported")
+			}
+		}
+
+		alt_geom.Source = fmt.Sprintf("%s-%s", alt_label, label)
+	}
+
+	if name != "" {
+
+		if !re_geotag_name.MatchString(name) {
+			return "", newParameterError("geotag", "must contain only letters, numbers and underscores")
+		}
+
+		alt_geom.Extras = []string{
+			name,
+		}
+	}
+
+	return alt_geom.String()
+}
+
+// managedProperties returns the geotag properties that are managed by WhosOnFirstGeotagWriter itself
+// (rather than assigned geotag values). Names ending in "*" are prefixes.
+func (n *GeotagNames) managedProperties() []string {
+
+	managed := []string{
+		n.Property("primary_label"),
+		n.Property("previous_*"),
+		n.Property("fov_label"),
+		n.Property("deleted_*"),
+	}
+
+	return append(managed, n.provenanceProperties()...)
+}
+
+// provenanceProperties returns the names of all the (namespaced) provenance properties.
+func (n *GeotagNames) provenanceProperties() []string {
+
+	props := make([]string, 0)
+
+	for _, k := range provenanceProperties() {
+		props = append(props, n.Property(k))
+	}
+
+	return props
+}
+
+// requestProperties returns the names of the (namespaced) provenance properties that change with every
+// request, rather than with the content of a geotag, and are ignored when deciding whether a document has
+// changed.
+func (n *GeotagNames) requestProperties() []string {
+
+	return []string{
+		n.Property(PROVENANCE_CREATED),
+		n.Property(PROVENANCE_REQUEST_ID),
+	}
+}
+
+// validatePropertyMap ensures that none of the properties in m are managed geotag properties.
+func (n *GeotagNames) validatePropertyMap(m PropertyMap) error {
+
+	for k, _ := range m {
+
+		for _, managed := range n.managedProperties() {
+
+			if k == managed || (strings.HasSuffix(managed, "*") && strings.HasPrefix(k, strings.TrimRight(managed, "*"))) {
+				return fmt.Errorf("Property '%s' can not be assigned a geotag value", k)
+			}
+		}
+	}
+
+	return nil
+}
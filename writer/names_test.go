@@ -0,0 +1,125 @@
+package writer
+
+import (
+	wof_uri "github.com/whosonfirst/go-whosonfirst-uri"
+	"testing"
+)
+
+func TestNewGeotagNames(t *testing.T) {
+
+	tests := []struct {
+		Label     string
+		Namespace string
+		Expected  *GeotagNames
+	}{
+		{"", "", &GeotagNames{Label: "geotag-fov", Namespace: "geotag:"}},
+		{"sfomuseum-fov", "sfomuseum:geotag_", &GeotagNames{Label: "sfomuseum-fov", Namespace: "sfomuseum:geotag_"}},
+		{"", "sfomuseum", &GeotagNames{Label: "geotag-fov", Namespace: "sfomuseum:"}},
+		{"sfomuseum", "", nil},
+		{"sfomuseum-camera", "", nil},
+		{"sfomuseum-pregeotag", "", nil},
+		{"", "wof:geotag_", nil},
+		{"", "Geotag:", nil},
+	}
+
+	for _, test := range tests {
+
+		n, err := NewGeotagNames(test.Label, test.Namespace)
+
+		if test.Expected == nil {
+
+			if err == nil {
+				t.Errorf("Expected label '%s' and namespace '%s' to be invalid", test.Label, test.Namespace)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Failed to create names for label '%s' and namespace '%s', %v", test.Label, test.Namespace, err)
+			continue
+		}
+
+		if *n != *test.Expected {
+			t.Errorf("Expected names %v, got %v", test.Expected, n)
+		}
+	}
+}
+
+func TestGeotagNamesProperty(t *testing.T) {
+
+	n, err := NewGeotagNames("", "sfomuseum:geotag_")
+
+	if err != nil {
+		t.Fatalf("Failed to create names, %v", err)
+	}
+
+	if n.Property("angle") != "sfomuseum:geotag_angle" {
+		t.Errorf("Unexpected property name '%s'", n.Property("angle"))
+	}
+
+	if !n.IsProperty("sfomuseum:geotag_angle") {
+		t.Errorf("Expected sfomuseum:geotag_angle to be a geotag property")
+	}
+
+	if n.IsProperty("sfomuseum:bearing") {
+		t.Errorf("Expected sfomuseum:bearing not to be a geotag property")
+	}
+}
+
+func TestGeotagNamesAltLabel(t *testing.T) {
+
+	n := DefaultGeotagNames()
+
+	tests := []struct {
+		URI      string
+		Name     string
+		Geometry string
+		Expected string
+		Invalid  bool
+	}{
+		{"1234567.geojson", "", "", "geotag-fov", false},
+		{"1234567.geojson", "night", "", "geotag-fov-night", false},
+		{"1234567.geojson", "", ALT_GEOMETRY_CAMERA, "geotag-camera", false},
+		{"1234567.geojson", "night", ALT_GEOMETRY_HORIZON, "geotag-horizon-night", false},
+		{"1234567-alt-sfomuseum-painting.geojson", "", "", "sfomuseum-painting-geotag-fov", false},
+		{"1234567.geojson", "not-valid", "", "", true},
+		{"1234567-alt-geotag-fov.geojson", "", "", "", true},
+		{"1234567-alt-geotag-camera.geojson", "", ALT_GEOMETRY_TARGET, "", true},
+	}
+
+	for _, test := range tests {
+
+		_, uri_args, err := wof_uri.ParseURI(test.URI)
+
+		if err != nil {
+			t.Fatalf("Failed to parse '%s', %v", test.URI, err)
+		}
+
+		var label string
+
+		if test.Geometry == "" {
+			label, err = n.AltLabel(uri_args, test.Name)
+		} else {
+			label, err = n.GeometryAltLabel(uri_args, test.Name, test.Geometry)
+		}
+
+		if test.Invalid {
+
+			if err == nil {
+				t.Errorf("Expected '%s' (%s, %s) to be invalid, got '%s'", test.URI, test.Name, test.Geometry, label)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Failed to derive alt label for '%s' (%s, %s), %v", test.URI, test.Name, test.Geometry, err)
+			continue
+		}
+
+		if label != test.Expected {
+			t.Errorf("Expected alt label for '%s' (%s, %s) to be '%s', got '%s'", test.URI, test.Name, test.Geometry, test.Expected, label)
+		}
+	}
+}
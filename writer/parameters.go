@@ -0,0 +1,134 @@
+package writer
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var re_source *regexp.Regexp
+
+func init() {
+	re_source = regexp.MustCompile(`^[a-zA-Z0-9_\-]+$`)
+}
+
+// ParseAllowedSources parses a comma-separated list of the "src:geom" values that may be assigned by
+// individual requests.
+func ParseAllowedSources(str_sources string) (map[string]bool, error) {
+
+	allowed := make(map[string]bool)
+
+	for _, src := range strings.Split(str_sources, ",") {
+
+		src = strings.TrimSpace(src)
+
+		if !re_source.MatchString(src) {
+			return nil, fmt.Errorf("Invalid source '%s'", src)
+		}
+
+		allowed[src] = true
+	}
+
+	return allowed, nil
+}
+
+// ParseAllowedUpdates parses a comma-separated list of the "update" values ("0" or "1") that may be
+// assigned by individual requests.
+func ParseAllowedUpdates(str_updates string) (map[bool]bool, error) {
+
+	allowed := make(map[bool]bool)
+
+	for _, str_update := range strings.Split(str_updates, ",") {
+
+		switch strings.TrimSpace(str_update) {
+		case "1":
+			allowed[true] = true
+		case "0":
+			allowed[false] = true
+		default:
+			return nil, fmt.Errorf("Invalid update value '%s'", str_update)
+		}
+	}
+
+	return allowed, nil
+}
+
+// requestSource returns the "src:geom" value for the geotag, which is the "source" parameter
+// in params, if present, or the writer's default source. Sources other than the default source must be
+// listed in the writer's "allowed_sources" parameter.
+func (wr *WhosOnFirstGeotagWriter) requestSource(params url.Values) (string, error) {
+
+	source := params.Get("source")
+
+	if source == "" || source == wr.geom_source {
+		return wr.geom_source, nil
+	}
+
+	if !re_source.MatchString(source) {
+		return "", newParameterError("source", "is not a valid source")
+	}
+
+	if !wr.allowed_sources[source] {
+
+		return "", &NotAllowedError{
+			Parameter: "source",
+			Value:     source,
+		}
+	}
+
+	return source, nil
+}
+
+// requestUpdate returns true if the record should be updated, which is the "update" parameter in
+// params, if present, or the writer's default. Values other than the default must be listed in the
+// writer's "allowed_updates" parameter.
+func (wr *WhosOnFirstGeotagWriter) requestUpdate(params url.Values) (bool, error) {
+
+	var update bool
+
+	switch params.Get("update") {
+	case "":
+		return wr.update, nil
+	case "1":
+		update = true
+	case "0":
+		update = false
+	default:
+		return false, newParameterError("update", "must be 0 or 1")
+	}
+
+	if update != wr.update && !wr.allowed_updates[update] {
+
+		return false, &NotAllowedError{
+			Parameter: "update",
+			Value:     params.Get("update"),
+		}
+	}
+
+	return update, nil
+}
+
+// requestOverride returns true if the "override" parameter in params allows deprecated and ceased records
+// to be geotagged. Overrides are only allowed if the writer's "allow_override" parameter is true.
+func (wr *WhosOnFirstGeotagWriter) requestOverride(params url.Values) (bool, error) {
+
+	switch params.Get("override") {
+	case "", "0":
+		return false, nil
+	case "1":
+		// pass
+	default:
+		return false, newParameterError("override", "must be 0 or 1")
+	}
+
+	if !wr.allow_override {
+
+		return false, &NotAllowedError{
+			Parameter: "override",
+			Value:     "1",
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,86 @@
+package writer
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestRequestParameters(t *testing.T) {
+
+	root, cleanup := newTestRoot(t)
+	defer cleanup()
+
+	wr := newTestWriter(t, root, url.Values{
+		"update":          []string{"1"},
+		"source":          []string{"sfomuseum"},
+		"allowed_sources": []string{"curator_a,curator_b"},
+		"allowed_updates": []string{"0"},
+	})
+
+	tests := []struct {
+		Query    string
+		Source   string
+		Update   bool
+		Override bool
+		Error    string
+	}{
+		{"", "sfomuseum", true, false, ""},
+		{"source=curator_a&update=0", "curator_a", false, false, ""},
+		{"source=curator_c", "", false, false, "not allowed"},
+		{"source=bad%20source", "", false, false, "invalid"},
+		{"update=maybe", "", false, false, "invalid"},
+		{"override=1", "", false, false, "not allowed"},
+		{"override=2", "", false, false, "invalid"},
+	}
+
+	for _, test := range tests {
+
+		params, err := url.ParseQuery(test.Query)
+
+		if err != nil {
+			t.Fatalf("Failed to parse query '%s', %v", test.Query, err)
+		}
+
+		source, err := wr.requestSource(params)
+
+		var update bool
+		var override bool
+
+		if err == nil {
+			update, err = wr.requestUpdate(params)
+		}
+
+		if err == nil {
+			override, err = wr.requestOverride(params)
+		}
+
+		switch test.Error {
+		case "not allowed":
+
+			_, ok := err.(*NotAllowedError)
+
+			if !ok {
+				t.Errorf("Expected '%s' to return a *NotAllowedError, got %v", test.Query, err)
+			}
+
+		case "invalid":
+
+			_, ok := err.(*ValidationError)
+
+			if !ok {
+				t.Errorf("Expected '%s' to return a *ValidationError, got %v", test.Query, err)
+			}
+
+		default:
+
+			if err != nil {
+				t.Errorf("Unexpected error for '%s', %v", test.Query, err)
+				continue
+			}
+
+			if source != test.Source || update != test.Update || override != test.Override {
+				t.Errorf("Expected '%s' to be %s, %t, %t, got %s, %t, %t", test.Query, test.Source, test.Update, test.Override, source, update, override)
+			}
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package writer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/paulmach/orb/geojson"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	wof_uri "github.com/whosonfirst/go-whosonfirst-uri"
+)
+
+const PREGEOTAG_FUNCTION string = "pregeotag"
+const PREGEOTAG_UNKNOWN_SRC string = "unknown"
+
+// stagePregeotag returns a staged alt file, labeled "{SRC_GEOM}-pregeotag", containing the current
+// geometry of the main record body (whose ID is wof_id) or nil if the record has already been geotagged
+// (in which case its geometry is no longer the original geometry). The label of the alt file is also
+// recorded in the record's "{NAMESPACE}previous_geom_alt" property.
+func (wr *WhosOnFirstGeotagWriter) stagePregeotag(ctx context.Context, wof_id int64, repo string, body []byte) (*stagedFeature, []byte, error) {
+
+	if gjson.GetBytes(body, fmt.Sprintf("properties.%s", wr.names.Property("primary_label"))).Exists() {
+		return nil, body, nil
+	}
+
+	geom_rsp := gjson.GetBytes(body, "geometry")
+
+	if !geom_rsp.Exists() {
+		return nil, body, nil
+	}
+
+	src_geom := gjson.GetBytes(body, "properties.src:geom").String()
+
+	if src_geom == "" {
+		src_geom = PREGEOTAG_UNKNOWN_SRC
+	}
+
+	alt_geom := &wof_uri.AltGeom{
+		Source:   src_geom,
+		Function: PREGEOTAG_FUNCTION,
+	}
+
+	alt_label, err := alt_geom.String()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	alt_uri_args := &wof_uri.URIArgs{
+		IsAlternate: true,
+		AltGeom:     alt_geom,
+	}
+
+	alt_path, err := wof_uri.Id2RelPath(wof_id, alt_uri_args)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	alt_props := map[string]interface{}{
+		"wof:id":        wof_id,
+		"wof:repo":      repo,
+		"src:alt_label": alt_label,
+		"src:geom":      src_geom,
+	}
+
+	geom, err := geojson.UnmarshalGeometry([]byte(geom_rsp.Raw))
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	spatial_props, alt_bbox, err := SpatialProperties(geom.Geometry())
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for k, v := range spatial_props {
+		alt_props[k] = v
+	}
+
+	alt_feature := &WhosOnFirstAltFeature{
+		Type:       "Feature",
+		Id:         wof_id,
+		Properties: alt_props,
+		Bbox:       alt_bbox,
+		Geometry:   json.RawMessage(geom_rsp.Raw),
+	}
+
+	alt_body, err := FormatAltFeature(alt_feature)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	body, err = sjson.SetBytes(body, fmt.Sprintf("properties.%s", wr.names.Property("previous_geom_alt")), alt_label)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	body, err = appendGeomAlt(body, alt_label)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f := &stagedFeature{
+		Role:     STAGED_PREGEOTAG,
+		Path:     alt_path,
+		Body:     alt_body,
+		Previous: wr.readPrevious(ctx, alt_path),
+	}
+
+	return f, body, nil
+}
+
+// restorePregeotag replaces the geometry of the main record body (whose ID is wof_id) with the geometry
+// of the alt file named in its "{NAMESPACE}previous_geom_alt" property, if present. The alt file itself is
+// left in place.
+func (wr *WhosOnFirstGeotagWriter) restorePregeotag(ctx context.Context, wof_id int64, body []byte) ([]byte, error) {
+
+	label_rsp := gjson.GetBytes(body, fmt.Sprintf("properties.%s", wr.names.Property("previous_geom_alt")))
+
+	if !label_rsp.Exists() {
+		return body, nil
+	}
+
+	alt_uri_args := wof_uri.NewAlternateURIArgs(label_rsp.String(), "")
+
+	alt_path, err := wof_uri.Id2RelPath(wof_id, alt_uri_args)
+
+	if err != nil {
+		return nil, err
+	}
+
+	alt_body, err := wr.readFeature(ctx, alt_path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	geom_rsp := gjson.GetBytes(alt_body, "geometry")
+
+	if !geom_rsp.Exists() {
+		return body, nil
+	}
+
+	return sjson.SetRawBytes(body, "geometry", []byte(geom_rsp.Raw))
+}
@@ -0,0 +1,183 @@
+package writer
+
+import (
+	"context"
+	"encoding/json"
+	geotag_writer "github.com/sfomuseum/go-www-geotag/writer"
+	"reflect"
+	"sort"
+)
+
+// WhosOnFirstGeotagPreview is the document returned by WhosOnFirstGeotagWriter in "dry run" mode.
+type WhosOnFirstGeotagPreview struct {
+	// Alt is the proposed geotag alt file or null if it would be removed.
+	Alt json.RawMessage `json:"alt"`
+	// Main is the proposed main record, if it would be updated.
+	Main json.RawMessage `json:"main,omitempty"`
+	// Record is the proposed alternate geometry, if an alternate geometry is being geotagged and would be updated.
+	Record json.RawMessage `json:"record,omitempty"`
+	// Pregeotag is the proposed alt file preserving the main record's original geometry, if it would be written.
+	Pregeotag json.RawMessage `json:"pregeotag,omitempty"`
+	// Geometries are the proposed camera, target and horizon alt files, if they would be written (or null if they would be removed).
+	Geometries []json.RawMessage `json:"geometries,omitempty"`
+	// Lifecycle describes how the lifecycle state of the requested record was handled.
+	Lifecycle *LifecycleDecision `json:"lifecycle,omitempty"`
+	// Diff is the list of changes for each document that would be written.
+	Diff []*FeatureDiff `json:"diff"`
+}
+
+// FeatureDiff describes the changes between the current and proposed versions of a document.
+type FeatureDiff struct {
+	Path       string          `json:"path"`
+	New        bool            `json:"new"`
+	Removed    bool            `json:"removed"`
+	Unchanged  bool            `json:"unchanged"`
+	Geometry   bool            `json:"geometry"`
+	Properties []*PropertyDiff `json:"properties"`
+}
+
+// PropertyDiff describes the change to a single property. Action is one of "added", "modified" or "removed".
+type PropertyDiff struct {
+	Property string      `json:"property"`
+	Action   string      `json:"action"`
+	Before   interface{} `json:"before,omitempty"`
+	After    interface{} `json:"after,omitempty"`
+}
+
+func (wr *WhosOnFirstGeotagWriter) writePreview(ctx context.Context, staged []*stagedFeature, lifecycle *LifecycleDecision) error {
+
+	target, err := geotag_writer.GetIOWriterFromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	preview := &WhosOnFirstGeotagPreview{
+		Lifecycle: lifecycle,
+		Diff:      make([]*FeatureDiff, 0),
+	}
+
+	for _, f := range staged {
+
+		switch f.Role {
+		case STAGED_ALT:
+			preview.Alt = f.Body
+		case STAGED_RECORD:
+			preview.Record = f.Body
+		case STAGED_MAIN:
+			preview.Main = f.Body
+		case STAGED_PREGEOTAG:
+			preview.Pregeotag = f.Body
+		case STAGED_GEOMETRY:
+			preview.Geometries = append(preview.Geometries, f.Body)
+		}
+
+		d, err := DiffFeatures(f.Previous, f.Body)
+
+		if err != nil {
+			return err
+		}
+
+		d.Path = f.Path
+
+		d.Unchanged, err = SameFeature(f.Previous, f.Body, wr.names.requestProperties()...)
+
+		if err != nil {
+			return err
+		}
+
+		preview.Diff = append(preview.Diff, d)
+	}
+
+	enc := json.NewEncoder(target)
+	return enc.Encode(preview)
+}
+
+// DiffFeatures compares the properties and geometries of two encoded GeoJSON features. If previous
+// is nil then all the properties in current are reported as "added" and if current is nil then all the
+// properties in previous are reported as "removed".
+func DiffFeatures(previous []byte, current []byte) (*FeatureDiff, error) {
+
+	type feature struct {
+		Properties map[string]interface{} `json:"properties"`
+		Geometry   interface{}            `json:"geometry"`
+	}
+
+	var before feature
+	var after feature
+
+	d := &FeatureDiff{
+		Properties: make([]*PropertyDiff, 0),
+	}
+
+	if previous == nil {
+		d.New = true
+	} else {
+
+		err := json.Unmarshal(previous, &before)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if current == nil {
+		d.Removed = true
+	} else {
+
+		err := json.Unmarshal(current, &after)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	d.Geometry = !reflect.DeepEqual(before.Geometry, after.Geometry)
+
+	keys := make([]string, 0)
+
+	for k, _ := range after.Properties {
+		keys = append(keys, k)
+	}
+
+	for k, _ := range before.Properties {
+
+		_, ok := after.Properties[k]
+
+		if !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+
+		v_before, in_before := before.Properties[k]
+		v_after, in_after := after.Properties[k]
+
+		var action string
+
+		switch {
+		case !in_before:
+			action = "added"
+		case !in_after:
+			action = "removed"
+		case !reflect.DeepEqual(v_before, v_after):
+			action = "modified"
+		default:
+			continue
+		}
+
+		pd := &PropertyDiff{
+			Property: k,
+			Action:   action,
+			Before:   v_before,
+			After:    v_after,
+		}
+
+		d.Properties = append(d.Properties, pd)
+	}
+
+	return d, nil
+}
@@ -0,0 +1,233 @@
+package writer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// The names of the geotag values that can be assigned to a record's properties.
+const (
+	VALUE_CAMERA_LATITUDE  string = "camera_latitude"
+	VALUE_CAMERA_LONGITUDE string = "camera_longitude"
+	VALUE_TARGET_LATITUDE  string = "target_latitude"
+	VALUE_TARGET_LONGITUDE string = "target_longitude"
+	VALUE_ANGLE            string = "angle"
+	VALUE_BEARING          string = "bearing"
+	VALUE_DISTANCE         string = "distance"
+	VALUE_CLIENT_BEARING   string = "client_bearing"
+	VALUE_CLIENT_DISTANCE  string = "client_distance"
+	VALUE_DISTANCE_MIN     string = "distance_min"
+	VALUE_DISTANCE_MAX     string = "distance_max"
+	VALUE_DEPICTS          string = "depicts"
+	VALUE_TARGET_WOF_ID    string = "target_wof_id"
+)
+
+var geotag_values map[string]bool
+
+var re_property *regexp.Regexp
+
+// protected_properties are the properties that are managed by WhosOnFirstGeotagWriter (or
+// go-whosonfirst-export) and can not be assigned geotag values.
+var protected_properties map[string]bool
+
+func init() {
+
+	geotag_values = map[string]bool{
+		VALUE_CAMERA_LATITUDE:  true,
+		VALUE_CAMERA_LONGITUDE: true,
+		VALUE_TARGET_LATITUDE:  true,
+		VALUE_TARGET_LONGITUDE: true,
+		VALUE_ANGLE:            true,
+		VALUE_BEARING:          true,
+		VALUE_DISTANCE:         true,
+		VALUE_CLIENT_BEARING:   true,
+		VALUE_CLIENT_DISTANCE:  true,
+		VALUE_DISTANCE_MIN:     true,
+		VALUE_DISTANCE_MAX:     true,
+		VALUE_DEPICTS:          true,
+		VALUE_TARGET_WOF_ID:    true,
+	}
+
+	re_property = regexp.MustCompile(`^[a-z0-9_]+:[a-zA-Z0-9_\-]+$`)
+
+	protected_properties = map[string]bool{
+		"wof:id":           true,
+		"wof:repo":         true,
+		"wof:parent_id":    true,
+		"wof:hierarchy":    true,
+		"wof:belongsto":    true,
+		"wof:placetype":    true,
+		"wof:lastmodified": true,
+		"wof:geomhash":     true,
+		"src:geom":         true,
+		"src:geom_alt":     true,
+		"src:alt_label":    true,
+	}
+}
+
+// PropertyMap maps the properties of a record to the names of the geotag values assigned to them
+// when the record is updated.
+type PropertyMap map[string]string
+
+// DefaultPropertyMap returns the PropertyMap used when none is configured.
+func DefaultPropertyMap() PropertyMap {
+	return NamespacedPropertyMap(DefaultGeotagNames())
+}
+
+// NamespacedPropertyMap returns the default PropertyMap with geotag properties named according to names.
+func NamespacedPropertyMap(names *GeotagNames) PropertyMap {
+
+	m := PropertyMap{
+		"lbl:latitude":                     VALUE_CAMERA_LATITUDE,
+		"lbl:longitude":                    VALUE_CAMERA_LONGITUDE,
+		names.Property("camera_latitude"):  VALUE_CAMERA_LATITUDE,
+		names.Property("camera_longitude"): VALUE_CAMERA_LONGITUDE,
+		names.Property("target_latitude"):  VALUE_TARGET_LATITUDE,
+		names.Property("target_longitude"): VALUE_TARGET_LONGITUDE,
+		names.Property("angle"):            VALUE_ANGLE,
+		names.Property("distance_min"):     VALUE_DISTANCE_MIN,
+		names.Property("distance_max"):     VALUE_DISTANCE_MAX,
+		"wof:depicts":                      VALUE_DEPICTS,
+		names.Property("target_wof_id"):    VALUE_TARGET_WOF_ID,
+	}
+
+	return m
+}
+
+// ParsePropertyMap parses a comma-separated list of "{PROPERTY}={VALUE}" pairs, for example
+// "lbl:latitude=camera_latitude,sfomuseum:bearing=bearing".
+func ParsePropertyMap(str_map string) (PropertyMap, error) {
+
+	m := PropertyMap{}
+
+	for _, str_pair := range strings.Split(str_map, ",") {
+
+		parts := strings.Split(strings.TrimSpace(str_pair), "=")
+
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid property mapping '%s'", str_pair)
+		}
+
+		m[parts[0]] = parts[1]
+	}
+
+	err := m.validate()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// ReadPropertyMap reads a JSON-encoded dictionary of property names and geotag value names from path.
+func ReadPropertyMap(path string) (PropertyMap, error) {
+
+	fh, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer fh.Close()
+
+	var m PropertyMap
+
+	dec := json.NewDecoder(fh)
+	err = dec.Decode(&m)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode property map, %v", err)
+	}
+
+	err = m.validate()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Merge adds all the mappings in other to m, replacing any existing mappings for the same property.
+func (m PropertyMap) Merge(other PropertyMap) {
+
+	for k, v := range other {
+		m[k] = v
+	}
+}
+
+// Remove removes the properties in to_remove from m. Properties ending in "*" are treated as prefixes,
+// for example "lbl:*" removes all the label properties.
+func (m PropertyMap) Remove(to_remove ...string) {
+
+	for _, r := range to_remove {
+
+		if strings.HasSuffix(r, "*") {
+
+			prefix := strings.TrimRight(r, "*")
+
+			for k, _ := range m {
+
+				if strings.HasPrefix(k, prefix) {
+					delete(m, k)
+				}
+			}
+
+			continue
+		}
+
+		delete(m, r)
+	}
+}
+
+// Apply returns the properties to assign, given values, and the properties to remove because their
+// values are known to be absent. Properties whose values are neither present nor absent (for example
+// because they were not computed) are left untouched.
+func (m PropertyMap) Apply(values map[string]interface{}, absent map[string]bool) (map[string]interface{}, []string) {
+
+	to_update := make(map[string]interface{})
+	to_remove := make([]string, 0)
+
+	for k, name := range m {
+
+		v, ok := values[name]
+
+		if ok {
+			to_update[k] = v
+			continue
+		}
+
+		if absent[name] {
+			to_remove = append(to_remove, k)
+		}
+	}
+
+	sort.Strings(to_remove)
+
+	return to_update, to_remove
+}
+
+func (m PropertyMap) validate() error {
+
+	for k, name := range m {
+
+		if !re_property.MatchString(k) {
+			return fmt.Errorf("Invalid property '%s', properties must be namespaced", k)
+		}
+
+		if protected_properties[k] || strings.HasPrefix(k, "geom:") {
+			return fmt.Errorf("Property '%s' can not be assigned a geotag value", k)
+		}
+
+		if !geotag_values[name] {
+			return fmt.Errorf("Invalid geotag value '%s' for property '%s'", name, k)
+		}
+	}
+
+	return nil
+}
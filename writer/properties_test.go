@@ -0,0 +1,113 @@
+package writer
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestParsePropertyMap(t *testing.T) {
+
+	m, err := ParsePropertyMap("sfomuseum:bearing=bearing, sfomuseum:distance=distance")
+
+	if err != nil {
+		t.Fatalf("Failed to parse property map, %v", err)
+	}
+
+	expected := PropertyMap{
+		"sfomuseum:bearing":  VALUE_BEARING,
+		"sfomuseum:distance": VALUE_DISTANCE,
+	}
+
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("Expected %v, got %v", expected, m)
+	}
+
+	for _, str_map := range []string{
+		"bearing=bearing",
+		"sfomuseum:bearing",
+		"sfomuseum:bearing=heading",
+		"wof:parent_id=target_wof_id",
+		"geom:latitude=camera_latitude",
+		"wof:lastmodified=angle",
+	} {
+
+		_, err := ParsePropertyMap(str_map)
+
+		if err == nil {
+			t.Errorf("Expected '%s' to be invalid", str_map)
+		}
+	}
+}
+
+func TestPropertyMapRemove(t *testing.T) {
+
+	m := DefaultPropertyMap()
+	m.Remove("lbl:*", "wof:depicts")
+
+	for _, k := range []string{"lbl:latitude", "lbl:longitude", "wof:depicts"} {
+
+		_, ok := m[k]
+
+		if ok {
+			t.Errorf("Expected %s to be removed", k)
+		}
+	}
+
+	_, ok := m["geotag:angle"]
+
+	if !ok {
+		t.Errorf("Expected geotag:angle to be kept")
+	}
+}
+
+func TestPropertyMapApply(t *testing.T) {
+
+	m := PropertyMap{
+		"lbl:latitude":        VALUE_CAMERA_LATITUDE,
+		"geotag:distance_min": VALUE_DISTANCE_MIN,
+		"wof:depicts":         VALUE_DEPICTS,
+	}
+
+	values := map[string]interface{}{
+		VALUE_CAMERA_LATITUDE: 37.6,
+	}
+
+	absent := map[string]bool{
+		VALUE_DISTANCE_MIN: true,
+	}
+
+	to_update, to_remove := m.Apply(values, absent)
+
+	if !reflect.DeepEqual(to_update, map[string]interface{}{"lbl:latitude": 37.6}) {
+		t.Errorf("Unexpected properties to update, %v", to_update)
+	}
+
+	// wof:depicts was neither computed nor known to be absent so it is left untouched
+
+	sort.Strings(to_remove)
+
+	if !reflect.DeepEqual(to_remove, []string{"geotag:distance_min"}) {
+		t.Errorf("Unexpected properties to remove, %v", to_remove)
+	}
+}
+
+func TestValidatePropertyMapManaged(t *testing.T) {
+
+	names := DefaultGeotagNames()
+
+	for _, k := range []string{"geotag:primary_label", "geotag:previous_properties", "geotag:author", "geotag:deleted_author"} {
+
+		err := names.validatePropertyMap(PropertyMap{k: VALUE_ANGLE})
+
+		if err == nil {
+			t.Errorf("Expected %s to be a managed property", k)
+		}
+	}
+
+	err := names.validatePropertyMap(DefaultPropertyMap())
+
+	if err != nil {
+		t.Errorf("Expected the default property map to be valid, %v", err)
+	}
+}
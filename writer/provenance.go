@@ -0,0 +1,122 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const PROVENANCE_KEY string = "github.com/sfomuseum/go-www-geotag-whosonfirst#provenance"
+
+// The names of the (geotag) properties used to record the provenance of a geotag.
+const (
+	PROVENANCE_AUTHOR     string = "author"
+	PROVENANCE_CREATED    string = "created"
+	PROVENANCE_SESSION_ID string = "session_id"
+	PROVENANCE_REQUEST_ID string = "request_id"
+	PROVENANCE_IMAGE_URL  string = "image_url"
+)
+
+// Provenance describes who made a geotag and how.
+type Provenance struct {
+	// Author is the name of the person who made the geotag.
+	Author string `json:"author,omitempty"`
+	// SessionId is the ID of the editing session in which the geotag was made.
+	SessionId string `json:"session_id,omitempty"`
+	// RequestId is the ID of the request that wrote the geotag.
+	RequestId string `json:"request_id,omitempty"`
+	// ImageURL is the URL of the image that was geotagged (for example when it was loaded using oEmbed).
+	ImageURL string `json:"image_url,omitempty"`
+}
+
+// Properties returns the provenance properties, named according to names, for a geotag written at t.
+// Empty values are omitted.
+func (p *Provenance) Properties(names *GeotagNames, t time.Time) map[string]interface{} {
+
+	props := map[string]interface{}{
+		names.Property(PROVENANCE_CREATED): t.Unix(),
+		"wof:lastmodified":                 t.Unix(),
+	}
+
+	optional := map[string]string{
+		PROVENANCE_AUTHOR:     p.Author,
+		PROVENANCE_SESSION_ID: p.SessionId,
+		PROVENANCE_REQUEST_ID: p.RequestId,
+		PROVENANCE_IMAGE_URL:  p.ImageURL,
+	}
+
+	for k, v := range optional {
+
+		if v != "" {
+			props[names.Property(k)] = v
+		}
+	}
+
+	return props
+}
+
+// DeletedProperties returns the provenance properties, named according to names and prefixed with
+// "deleted_" (for example "geotag:deleted_author"), that record who deprecated a geotag. Empty values are
+// omitted.
+func (p *Provenance) DeletedProperties(names *GeotagNames) map[string]interface{} {
+
+	props := make(map[string]interface{})
+
+	optional := map[string]string{
+		PROVENANCE_AUTHOR:     p.Author,
+		PROVENANCE_SESSION_ID: p.SessionId,
+		PROVENANCE_REQUEST_ID: p.RequestId,
+	}
+
+	for k, v := range optional {
+
+		if v != "" {
+			props[names.Property(fmt.Sprintf("deleted_%s", k))] = v
+		}
+	}
+
+	return props
+}
+
+// provenanceProperties returns the (unqualified) names of all the provenance properties.
+func provenanceProperties() []string {
+
+	return []string{
+		PROVENANCE_AUTHOR,
+		PROVENANCE_CREATED,
+		PROVENANCE_SESSION_ID,
+		PROVENANCE_REQUEST_ID,
+		PROVENANCE_IMAGE_URL,
+	}
+}
+
+// SetProvenanceWithContext assigns the provenance of a geotag (for example derived from an HTTP request)
+// to ctx for use by WhosOnFirstGeotagWriter.
+func SetProvenanceWithContext(ctx context.Context, p *Provenance) (context.Context, error) {
+
+	ctx = context.WithValue(ctx, PROVENANCE_KEY, p)
+	return ctx, nil
+}
+
+// GetProvenanceFromContext returns the provenance assigned to ctx or an empty Provenance instance if
+// none has been assigned.
+func GetProvenanceFromContext(ctx context.Context) (*Provenance, error) {
+
+	v := ctx.Value(PROVENANCE_KEY)
+
+	if v == nil {
+		return &Provenance{}, nil
+	}
+
+	var p *Provenance
+
+	switch v.(type) {
+	case *Provenance:
+		p = v.(*Provenance)
+	default:
+		return nil, errors.New("Invalid provenance")
+	}
+
+	return p, nil
+}
@@ -0,0 +1,41 @@
+package writer
+
+import (
+	"context"
+	"encoding/json"
+	geotag_writer "github.com/sfomuseum/go-www-geotag/writer"
+)
+
+const STATUS_WRITTEN string = "written"
+const STATUS_DELETED string = "deleted"
+const STATUS_UNCHANGED string = "unchanged"
+
+// WhosOnFirstGeotagReport is the document written by WhosOnFirstGeotagWriter once a geotag has been
+// successfully written (or deleted).
+type WhosOnFirstGeotagReport struct {
+	// Id is the ID of the record that was geotagged.
+	Id int64 `json:"id"`
+	// Status is one of STATUS_WRITTEN, STATUS_DELETED or STATUS_UNCHANGED (if nothing needed to be written).
+	Status string `json:"status"`
+	// ETag is the entity tag of the geotag after it was written (or deleted).
+	ETag string `json:"etag,omitempty"`
+	// RequestId is the ID of the request that wrote the geotag, if known.
+	RequestId string `json:"request_id,omitempty"`
+	// Unchanged are the paths of the documents that were not written because their content had not changed.
+	Unchanged []string `json:"unchanged,omitempty"`
+	// Lifecycle describes how the lifecycle state of the requested record was handled.
+	Lifecycle *LifecycleDecision `json:"lifecycle,omitempty"`
+}
+
+// writeReport writes report to the io.Writer instance associated with ctx, if there is one.
+func (wr *WhosOnFirstGeotagWriter) writeReport(ctx context.Context, report *WhosOnFirstGeotagReport) error {
+
+	target, err := geotag_writer.GetIOWriterFromContext(ctx)
+
+	if err != nil {
+		return nil
+	}
+
+	enc := json.NewEncoder(target)
+	return enc.Encode(report)
+}
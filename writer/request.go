@@ -0,0 +1,135 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"net/url"
+)
+
+const REQUEST_PARAMETERS_KEY string = "github.com/sfomuseum/go-www-geotag-whosonfirst#request_parameters"
+
+const FEATURE_BODY_KEY string = "github.com/sfomuseum/go-www-geotag-whosonfirst#feature_body"
+
+const IF_MATCH_KEY string = "github.com/sfomuseum/go-www-geotag-whosonfirst#if_match"
+
+const IF_NONE_MATCH_KEY string = "github.com/sfomuseum/go-www-geotag-whosonfirst#if_none_match"
+
+// SetRequestParametersWithContext assigns per-request parameters (for example query
+// parameters from an HTTP request) to ctx for use by WhosOnFirstGeotagWriter.
+func SetRequestParametersWithContext(ctx context.Context, params url.Values) (context.Context, error) {
+
+	ctx = context.WithValue(ctx, REQUEST_PARAMETERS_KEY, params)
+	return ctx, nil
+}
+
+// GetRequestParametersFromContext returns the per-request parameters assigned to ctx
+// or an empty url.Values instance if none have been assigned.
+func GetRequestParametersFromContext(ctx context.Context) (url.Values, error) {
+
+	v := ctx.Value(REQUEST_PARAMETERS_KEY)
+
+	if v == nil {
+		return url.Values{}, nil
+	}
+
+	var params url.Values
+
+	switch v.(type) {
+	case url.Values:
+		params = v.(url.Values)
+	default:
+		return nil, errors.New("Invalid request parameters")
+	}
+
+	return params, nil
+}
+
+// SetFeatureBodyWithContext assigns the raw (encoded) geotag feature to ctx so that properties
+// not defined by geotag.GeotagProperties (for example "distance_min" and "distance_max") are
+// available to WhosOnFirstGeotagWriter.
+func SetFeatureBodyWithContext(ctx context.Context, body []byte) (context.Context, error) {
+
+	ctx = context.WithValue(ctx, FEATURE_BODY_KEY, body)
+	return ctx, nil
+}
+
+// GetFeatureBodyFromContext returns the raw (encoded) geotag feature assigned to ctx or nil if
+// none has been assigned.
+func GetFeatureBodyFromContext(ctx context.Context) ([]byte, error) {
+
+	v := ctx.Value(FEATURE_BODY_KEY)
+
+	if v == nil {
+		return nil, nil
+	}
+
+	var body []byte
+
+	switch v.(type) {
+	case []byte:
+		body = v.([]byte)
+	default:
+		return nil, errors.New("Invalid feature body")
+	}
+
+	return body, nil
+}
+
+// SetIfMatchWithContext assigns the value of an "If-Match" header to ctx. WhosOnFirstGeotagWriter will
+// refuse to write (or delete) a geotag whose current entity tag does not match it.
+func SetIfMatchWithContext(ctx context.Context, if_match string) (context.Context, error) {
+
+	ctx = context.WithValue(ctx, IF_MATCH_KEY, if_match)
+	return ctx, nil
+}
+
+// GetIfMatchFromContext returns the "If-Match" value assigned to ctx or an empty string if none has been assigned.
+func GetIfMatchFromContext(ctx context.Context) (string, error) {
+
+	v := ctx.Value(IF_MATCH_KEY)
+
+	if v == nil {
+		return "", nil
+	}
+
+	var if_match string
+
+	switch v.(type) {
+	case string:
+		if_match = v.(string)
+	default:
+		return "", errors.New("Invalid If-Match value")
+	}
+
+	return if_match, nil
+}
+
+// SetIfNoneMatchWithContext assigns the value of an "If-None-Match" header to ctx. WhosOnFirstGeotagWriter will
+// refuse to write (or delete) a geotag whose current entity tag matches it or, if it is "*", that already exists.
+func SetIfNoneMatchWithContext(ctx context.Context, if_none_match string) (context.Context, error) {
+
+	ctx = context.WithValue(ctx, IF_NONE_MATCH_KEY, if_none_match)
+	return ctx, nil
+}
+
+// GetIfNoneMatchFromContext returns the "If-None-Match" value assigned to ctx or an empty string if none has
+// been assigned.
+func GetIfNoneMatchFromContext(ctx context.Context) (string, error) {
+
+	v := ctx.Value(IF_NONE_MATCH_KEY)
+
+	if v == nil {
+		return "", nil
+	}
+
+	var if_none_match string
+
+	switch v.(type) {
+	case string:
+		if_none_match = v.(string)
+	default:
+		return "", errors.New("Invalid If-None-Match value")
+	}
+
+	return if_none_match, nil
+}
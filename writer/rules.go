@@ -0,0 +1,146 @@
+package writer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// UPDATE_GEOMETRY replaces the record's geometry with the point of view and updates its label and geotag properties.
+const UPDATE_GEOMETRY string = "geometry"
+
+// UPDATE_LABEL updates the record's label and geotag properties but not its geometry.
+const UPDATE_LABEL string = "label"
+
+// UPDATE_PROPERTIES only updates the record's geotag properties.
+const UPDATE_PROPERTIES string = "properties"
+
+// UPDATE_REFUSE refuses to update the record.
+const UPDATE_REFUSE string = "refuse"
+
+// UPDATE_RULE_WILDCARD matches any placetype or geometry type.
+const UPDATE_RULE_WILDCARD string = "*"
+
+// UpdateRule decides how a record with a given placetype and geometry type is updated by a geotag.
+type UpdateRule struct {
+	// Placetype is the "wof:placetype" property of the records the rule applies to, or "*".
+	Placetype string `json:"placetype"`
+	// GeometryType is the GeoJSON geometry type of the records the rule applies to, or "*".
+	GeometryType string `json:"geometry"`
+	// Action is one of UPDATE_GEOMETRY, UPDATE_LABEL, UPDATE_PROPERTIES or UPDATE_REFUSE.
+	Action string `json:"action"`
+}
+
+// Matches returns true if the rule applies to a record with placetype and geom_type.
+func (r *UpdateRule) Matches(placetype string, geom_type string) bool {
+
+	if r.Placetype != UPDATE_RULE_WILDCARD && r.Placetype != placetype {
+		return false
+	}
+
+	if r.GeometryType != UPDATE_RULE_WILDCARD && r.GeometryType != geom_type {
+		return false
+	}
+
+	return true
+}
+
+func (r *UpdateRule) validate() error {
+
+	if r.Placetype == "" || r.GeometryType == "" {
+		return fmt.Errorf("Invalid update rule '%s:%s:%s'", r.Placetype, r.GeometryType, r.Action)
+	}
+
+	switch r.Action {
+	case UPDATE_GEOMETRY, UPDATE_LABEL, UPDATE_PROPERTIES, UPDATE_REFUSE:
+		return nil
+	default:
+		return fmt.Errorf("Invalid update rule action '%s'", r.Action)
+	}
+}
+
+// UpdateRules is an ordered list of update rules. The first matching rule wins.
+type UpdateRules []*UpdateRule
+
+// Action returns the action of the first rule matching placetype and geom_type, or UPDATE_GEOMETRY
+// if there are no matching rules.
+func (rules UpdateRules) Action(placetype string, geom_type string) string {
+
+	for _, r := range rules {
+
+		if r.Matches(placetype, geom_type) {
+			return r.Action
+		}
+	}
+
+	return UPDATE_GEOMETRY
+}
+
+// ParseUpdateRules parses a comma-separated list of "{PLACETYPE}:{GEOMETRY_TYPE}:{ACTION}" rules, for
+// example "venue:Polygon:label,venue:MultiPolygon:label,*:*:geometry".
+func ParseUpdateRules(str_rules string) (UpdateRules, error) {
+
+	rules := make(UpdateRules, 0)
+
+	for _, str_rule := range strings.Split(str_rules, ",") {
+
+		parts := strings.Split(strings.TrimSpace(str_rule), ":")
+
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("Invalid update rule '%s'", str_rule)
+		}
+
+		r := &UpdateRule{
+			Placetype:    parts[0],
+			GeometryType: parts[1],
+			Action:       parts[2],
+		}
+
+		err := r.validate()
+
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, r)
+	}
+
+	return rules, nil
+}
+
+// ReadUpdateRules reads a JSON-encoded list of UpdateRule instances from path.
+func ReadUpdateRules(path string) (UpdateRules, error) {
+
+	fh, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer fh.Close()
+
+	var rules UpdateRules
+
+	dec := json.NewDecoder(fh)
+	err = dec.Decode(&rules)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode update rules, %v", err)
+	}
+
+	for i, r := range rules {
+
+		if r == nil {
+			return nil, fmt.Errorf("Invalid update rule at position %d, rules can not be null", i)
+		}
+
+		err := r.validate()
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return rules, nil
+}
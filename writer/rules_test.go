@@ -0,0 +1,140 @@
+package writer
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdateRulesAction(t *testing.T) {
+
+	rules, err := ParseUpdateRules("venue:Polygon:label, venue:*:properties,building:*:refuse,*:Point:geometry")
+
+	if err != nil {
+		t.Fatalf("Failed to parse update rules, %v", err)
+	}
+
+	tests := []struct {
+		Placetype    string
+		GeometryType string
+		Action       string
+	}{
+		{"venue", "Polygon", UPDATE_LABEL},
+		{"venue", "MultiPolygon", UPDATE_PROPERTIES},
+		{"building", "Polygon", UPDATE_REFUSE},
+		{"locality", "Point", UPDATE_GEOMETRY},
+		{"locality", "Polygon", UPDATE_GEOMETRY},
+	}
+
+	for _, test := range tests {
+
+		action := rules.Action(test.Placetype, test.GeometryType)
+
+		if action != test.Action {
+			t.Errorf("Expected %s:%s to be %s, got %s", test.Placetype, test.GeometryType, test.Action, action)
+		}
+	}
+}
+
+func TestParseUpdateRulesInvalid(t *testing.T) {
+
+	for _, str_rules := range []string{
+		"venue:Polygon",
+		"venue:Polygon:label:extra",
+		":Polygon:label",
+		"venue:Polygon:delete",
+		"venue:Polygon:label,",
+	} {
+
+		_, err := ParseUpdateRules(str_rules)
+
+		if err == nil {
+			t.Errorf("Expected '%s' to be invalid", str_rules)
+		}
+	}
+}
+
+func TestReadUpdateRules(t *testing.T) {
+
+	root, err := ioutil.TempDir("", "rules")
+
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory, %v", err)
+	}
+
+	defer os.RemoveAll(root)
+
+	tests := []struct {
+		Body  string
+		Valid bool
+	}{
+		{`[{"placetype":"venue","geometry":"*","action":"label"}]`, true},
+		{`[null]`, false},
+		{`[{"placetype":"venue","geometry":"*","action":"delete"}]`, false},
+		{`{"placetype":"venue"}`, false},
+	}
+
+	for i, test := range tests {
+
+		path := filepath.Join(root, "rules.json")
+
+		err := ioutil.WriteFile(path, []byte(test.Body), 0644)
+
+		if err != nil {
+			t.Fatalf("Failed to write rules file, %v", err)
+		}
+
+		rules, err := ReadUpdateRules(path)
+
+		if !test.Valid {
+
+			if err == nil {
+				t.Errorf("Expected rules %d (%s) to be invalid", i, test.Body)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("Failed to read rules %d, %v", i, err)
+		}
+
+		if rules.Action("venue", "Polygon") != UPDATE_LABEL {
+			t.Errorf("Expected rules %d to label venues", i)
+		}
+	}
+}
+
+func TestWriteFeatureRefused(t *testing.T) {
+
+	root, cleanup := newTestRoot(t)
+	defer cleanup()
+
+	record := readTestRecord(t)
+	writeTestFeature(t, root, test_main_path, record)
+
+	wr := newTestWriter(t, root, url.Values{
+		"update": []string{"1"},
+		"rules":  []string{"venue:*:refuse"},
+	})
+
+	err := wr.WriteFeature(context.Background(), "1234567", readTestGeotag(t))
+
+	_, ok := err.(*RefusedError)
+
+	if !ok {
+		t.Fatalf("Expected a *RefusedError, got %v", err)
+	}
+
+	if !bytes.Equal(readTestFeature(t, root, test_main_path), record) {
+		t.Errorf("Expected the record to be untouched")
+	}
+
+	if readTestFeature(t, root, test_alt_path) != nil {
+		t.Errorf("Expected no geotag alt file to be written")
+	}
+}
@@ -0,0 +1,77 @@
+package writer
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/paulmach/orb/planar"
+	"github.com/sfomuseum/go-www-geotag-whosonfirst/geo"
+)
+
+// SpatialProperties returns the "geom:*" properties (the bounding box, centroid, planar area in square degrees,
+// geodesic area in square meters and hash) and the GeoJSON bounding box for the geometry g. The planar area is
+// assigned to "geom:area", as it is by go-whosonfirst-export, and the geodesic area to "geom:area_square_m".
+func SpatialProperties(g orb.Geometry) (map[string]interface{}, []float64, error) {
+
+	bound := g.Bound()
+
+	centroid, _ := planar.CentroidArea(g)
+
+	hash, err := GeometryHash(g)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	props := map[string]interface{}{
+		"geom:bbox":          fmt.Sprintf("%.6f,%.6f,%.6f,%.6f", bound.Min.X(), bound.Min.Y(), bound.Max.X(), bound.Max.Y()),
+		"geom:latitude":      centroid.Y(),
+		"geom:longitude":     centroid.X(),
+		"geom:area":          planar.Area(g),
+		"geom:area_square_m": geo.Area(g),
+		"geom:hash":          hash,
+	}
+
+	bbox := []float64{
+		bound.Min.X(),
+		bound.Min.Y(),
+		bound.Max.X(),
+		bound.Max.Y(),
+	}
+
+	return props, bbox, nil
+}
+
+// GeometryHash returns the MD5 hash of the GeoJSON encoding of g, computed the same way as the
+// "wof:geomhash" property assigned by go-whosonfirst-export.
+func GeometryHash(g orb.Geometry) (string, error) {
+
+	enc, err := json.Marshal(geojson.NewGeometry(g))
+
+	if err != nil {
+		return "", err
+	}
+
+	// round-trip the geometry so that its keys are sorted the same way that
+	// go-whosonfirst-export sorts them
+
+	var v interface{}
+
+	err = json.Unmarshal(enc, &v)
+
+	if err != nil {
+		return "", err
+	}
+
+	enc, err = json.Marshal(v)
+
+	if err != nil {
+		return "", err
+	}
+
+	hash := md5.Sum(enc)
+	return hex.EncodeToString(hash[:]), nil
+}
@@ -0,0 +1,56 @@
+package writer
+
+import (
+	"github.com/paulmach/orb"
+	"math"
+	"testing"
+)
+
+func TestSpatialProperties(t *testing.T) {
+
+	square := orb.Polygon{
+		orb.Ring{{0.0, 0.0}, {1.0, 0.0}, {1.0, 1.0}, {0.0, 1.0}, {0.0, 0.0}},
+	}
+
+	props, bbox, err := SpatialProperties(square)
+
+	if err != nil {
+		t.Fatalf("Failed to derive spatial properties, %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"geom:bbox":      "0.000000,0.000000,1.000000,1.000000",
+		"geom:latitude":  0.5,
+		"geom:longitude": 0.5,
+		"geom:area":      1.0,
+	}
+
+	for k, v := range expected {
+
+		if props[k] != v {
+			t.Errorf("Expected %s to be %v, got %v", k, v, props[k])
+		}
+	}
+
+	// a one degree square at the equator is roughly 111km on each side
+
+	area_m := props["geom:area_square_m"].(float64)
+
+	if math.Abs(area_m-12308778361.47) > 1e6 {
+		t.Errorf("Unexpected geom:area_square_m %f", area_m)
+	}
+
+	if len(bbox) != 4 || bbox[0] != 0.0 || bbox[3] != 1.0 {
+		t.Errorf("Unexpected bounding box %v", bbox)
+	}
+
+	hash, err := GeometryHash(square)
+
+	if err != nil {
+		t.Fatalf("Failed to derive geometry hash, %v", err)
+	}
+
+	if props["geom:hash"] != hash {
+		t.Errorf("Expected geom:hash to be %s, got %v", hash, props["geom:hash"])
+	}
+}
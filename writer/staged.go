@@ -0,0 +1,216 @@
+package writer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const STAGED_ALT string = "alt"
+const STAGED_RECORD string = "record"
+const STAGED_MAIN string = "main"
+const STAGED_PREGEOTAG string = "pregeotag"
+const STAGED_GEOMETRY string = "geometry"
+
+// stagedFeature is a Who's On First document that has been computed by WhosOnFirstGeotagWriter
+// but not written yet.
+type stagedFeature struct {
+	// Role is one of STAGED_ALT (the geotag alt file), STAGED_RECORD (an alternate geometry being geotagged), STAGED_MAIN (the main record), STAGED_PREGEOTAG (the main record's original geometry) or STAGED_GEOMETRY (an additional camera, target or horizon geometry).
+	Role string `json:"role"`
+	// Path is the path relative to the writer's root of the document.
+	Path string `json:"path"`
+	// Body is the encoded document to write or nil if the document should be removed.
+	Body []byte `json:"body"`
+	// Previous is the current encoded document or nil if it does not exist.
+	Previous []byte `json:"previous"`
+}
+
+// CommitError is returned when one or more staged documents fail to be written. Any documents
+// that were written before the failure are rolled back (restored or removed).
+type CommitError struct {
+	// Step is the role of the staged document that failed to be written.
+	Step string
+	// Path is the path of the staged document that failed to be written.
+	Path string
+	// Err is the error returned by the underlying writer.
+	Err error
+	// RollbackErrors are any errors encountered rolling back documents that had already been written.
+	RollbackErrors []error
+}
+
+func (e *CommitError) Error() string {
+
+	msg := fmt.Sprintf("Failed to write %s document (%s), %v", e.Step, e.Path, e.Err)
+
+	if len(e.RollbackErrors) > 0 {
+
+		str_errors := make([]string, len(e.RollbackErrors))
+
+		for i, err := range e.RollbackErrors {
+			str_errors[i] = err.Error()
+		}
+
+		msg = fmt.Sprintf("%s. Additionally, failed to roll back previous writes: %s", msg, strings.Join(str_errors, "; "))
+	}
+
+	return msg
+}
+
+// commit writes (or removes) each staged document, in order. If a write fails then all the documents written
+// before it are restored to their previous state (or removed if they did not exist) and a
+// *CommitError is returned. If the writer has a journal then the staged documents are recorded in it
+// before anything is written and the journal entry is removed once they have been written (or successfully
+// rolled back).
+func (wr *WhosOnFirstGeotagWriter) commit(ctx context.Context, staged []*stagedFeature) error {
+
+	if len(staged) == 0 {
+		return nil
+	}
+
+	journal_path, err := wr.journalBegin(ctx, staged)
+
+	if err != nil {
+		return fmt.Errorf("Failed to write journal entry, %v", err)
+	}
+
+	for i, f := range staged {
+
+		var err error
+
+		if f.Body != nil {
+			err = wr.writeFeature(ctx, f.Path, f.Body)
+		} else {
+			err = wr.removeFeature(ctx, f.Path)
+		}
+
+		if err == nil {
+			continue
+		}
+
+		commit_err := &CommitError{
+			Step:           f.Role,
+			Path:           f.Path,
+			Err:            err,
+			RollbackErrors: make([]error, 0),
+		}
+
+		for j := i - 1; j >= 0; j-- {
+
+			err := wr.rollback(ctx, staged[j])
+
+			if err != nil {
+				commit_err.RollbackErrors = append(commit_err.RollbackErrors, err)
+			}
+		}
+
+		// if anything failed to be rolled back then the journal entry is left in place so that
+		// it can be recovered later
+
+		if len(commit_err.RollbackErrors) == 0 {
+			wr.journalEnd(ctx, journal_path)
+		}
+
+		return commit_err
+	}
+
+	return wr.journalEnd(ctx, journal_path)
+}
+
+func (wr *WhosOnFirstGeotagWriter) rollback(ctx context.Context, f *stagedFeature) error {
+
+	var err error
+
+	if f.Previous != nil {
+		err = wr.writeFeature(ctx, f.Path, f.Previous)
+	} else {
+		err = wr.removeFeature(ctx, f.Path)
+	}
+
+	if err != nil {
+		return fmt.Errorf("Failed to roll back %s document (%s), %v", f.Role, f.Path, err)
+	}
+
+	return nil
+}
+
+// skipUnchanged removes the staged documents whose content (ignoring their "wof:lastmodified" property and the
+// properties in ignore) is the same as their previous version from staged. The body of each unchanged document is replaced by its previous
+// version. It returns the documents that still need to be written and the paths of the unchanged documents.
+func skipUnchanged(staged []*stagedFeature, ignore ...string) ([]*stagedFeature, []string, error) {
+
+	changed := make([]*stagedFeature, 0)
+	unchanged := make([]string, 0)
+
+	for _, f := range staged {
+
+		same, err := SameFeature(f.Previous, f.Body, ignore...)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !same {
+			changed = append(changed, f)
+			continue
+		}
+
+		f.Body = f.Previous
+		unchanged = append(unchanged, f.Path)
+	}
+
+	return changed, unchanged, nil
+}
+
+// SameFeature returns true if the encoded features previous and current are equivalent, ignoring their
+// "wof:lastmodified" properties (and any properties in ignore), the order of their "wof:belongsto" properties
+// (which is not stable across exports) and formatting. If either feature is nil it returns false.
+func SameFeature(previous []byte, current []byte, ignore ...string) (bool, error) {
+
+	if previous == nil || current == nil {
+		return false, nil
+	}
+
+	var before map[string]interface{}
+	var after map[string]interface{}
+
+	err := json.Unmarshal(previous, &before)
+
+	if err != nil {
+		return false, err
+	}
+
+	err = json.Unmarshal(current, &after)
+
+	if err != nil {
+		return false, err
+	}
+
+	for _, f := range []map[string]interface{}{before, after} {
+
+		props, ok := f["properties"].(map[string]interface{})
+
+		if !ok {
+			continue
+		}
+
+		delete(props, "wof:lastmodified")
+
+		for _, k := range ignore {
+			delete(props, k)
+		}
+
+		belongsto, ok := props["wof:belongsto"].([]interface{})
+
+		if ok {
+
+			sort.Slice(belongsto, func(i, j int) bool {
+				return fmt.Sprintf("%v", belongsto[i]) < fmt.Sprintf("%v", belongsto[j])
+			})
+		}
+	}
+
+	return reflect.DeepEqual(before, after), nil
+}
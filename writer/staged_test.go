@@ -0,0 +1,320 @@
+package writer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/tidwall/gjson"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const test_main_path string = "123/456/7/1234567.geojson"
+const test_alt_path string = "123/456/7/1234567-alt-geotag-fov.geojson"
+
+// newTestRoot creates a temporary directory, with a "data" directory for documents, and returns its path
+// and a function to remove it.
+func newTestRoot(t *testing.T) (string, func()) {
+
+	root, err := ioutil.TempDir("", "geotag")
+
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory, %v", err)
+	}
+
+	cleanup := func() {
+		os.RemoveAll(root)
+	}
+
+	err = os.MkdirAll(filepath.Join(root, "data"), 0755)
+
+	if err != nil {
+		cleanup()
+		t.Fatalf("Failed to create data directory, %v", err)
+	}
+
+	return root, cleanup
+}
+
+// newTestWriter returns a WhosOnFirstGeotagWriter that reads and writes documents in the data directory
+// in root, with any additional writer parameters in extras.
+func newTestWriter(t *testing.T, root string, extras url.Values) *WhosOnFirstGeotagWriter {
+
+	data := filepath.Join(root, "data")
+
+	q := url.Values{}
+	q.Set("writer", fmt.Sprintf("fs://%s", data))
+	q.Set("reader", fmt.Sprintf("fs://%s", data))
+
+	for k, v := range extras {
+		q[k] = v
+	}
+
+	uri := fmt.Sprintf("whosonfirst://?%s", q.Encode())
+
+	wr, err := NewWhosOnFirstGeotagWriter(context.Background(), uri)
+
+	if err != nil {
+		t.Fatalf("Failed to create writer, %v", err)
+	}
+
+	return wr.(*WhosOnFirstGeotagWriter)
+}
+
+// readTestFeature returns the body of the document at path, relative to the data directory in root, or nil
+// if it does not exist.
+func readTestFeature(t *testing.T, root string, path string) []byte {
+
+	body, err := ioutil.ReadFile(filepath.Join(root, "data", path))
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		t.Fatalf("Failed to read %s, %v", path, err)
+	}
+
+	return body
+}
+
+func writeTestFeature(t *testing.T, root string, path string, body []byte) {
+
+	abs_path := filepath.Join(root, "data", path)
+
+	err := os.MkdirAll(filepath.Dir(abs_path), 0755)
+
+	if err == nil {
+		err = ioutil.WriteFile(abs_path, body, 0644)
+	}
+
+	if err != nil {
+		t.Fatalf("Failed to write %s, %v", path, err)
+	}
+}
+
+// failingPath returns a path, relative to the data directory in root, that can not be written because its
+// parent is a file.
+func failingPath(t *testing.T, root string) string {
+	writeTestFeature(t, root, "999/999/9", []byte("not a directory"))
+	return "999/999/9/9999999.geojson"
+}
+
+func TestCommit(t *testing.T) {
+
+	tests := []struct {
+		Name     string
+		Existing map[string][]byte
+		Staged   []*stagedFeature
+		Expected map[string][]byte
+	}{
+		{
+			Name: "Create",
+			Staged: []*stagedFeature{
+				{Role: STAGED_ALT, Path: test_alt_path, Body: []byte("alt")},
+				{Role: STAGED_MAIN, Path: test_main_path, Body: []byte("main")},
+			},
+			Expected: map[string][]byte{
+				test_alt_path:  []byte("alt"),
+				test_main_path: []byte("main"),
+			},
+		},
+		{
+			Name: "Update and remove",
+			Existing: map[string][]byte{
+				test_alt_path:  []byte("alt"),
+				test_main_path: []byte("main"),
+			},
+			Staged: []*stagedFeature{
+				{Role: STAGED_ALT, Path: test_alt_path, Body: nil, Previous: []byte("alt")},
+				{Role: STAGED_MAIN, Path: test_main_path, Body: []byte("main v2"), Previous: []byte("main")},
+			},
+			Expected: map[string][]byte{
+				test_alt_path:  nil,
+				test_main_path: []byte("main v2"),
+			},
+		},
+	}
+
+	for _, test := range tests {
+
+		root, cleanup := newTestRoot(t)
+		defer cleanup()
+
+		wr := newTestWriter(t, root, nil)
+
+		for path, body := range test.Existing {
+			writeTestFeature(t, root, path, body)
+		}
+
+		err := wr.commit(context.Background(), test.Staged)
+
+		if err != nil {
+			t.Fatalf("%s: failed to commit, %v", test.Name, err)
+		}
+
+		for path, expected := range test.Expected {
+
+			body := readTestFeature(t, root, path)
+
+			if !bytes.Equal(body, expected) {
+				t.Errorf("%s: expected %s to be '%s', got '%s'", test.Name, path, expected, body)
+			}
+		}
+	}
+}
+
+func TestCommitRollback(t *testing.T) {
+
+	root, cleanup := newTestRoot(t)
+	defer cleanup()
+
+	journal := filepath.Join(root, "journal")
+
+	wr := newTestWriter(t, root, url.Values{"journal": []string{journal}})
+
+	pregeotag_path := "123/456/7/1234567-alt-sfomuseum-pregeotag.geojson"
+
+	writeTestFeature(t, root, test_main_path, []byte("main"))
+	writeTestFeature(t, root, pregeotag_path, []byte("pregeotag"))
+
+	bad_path := failingPath(t, root)
+
+	staged := []*stagedFeature{
+		{Role: STAGED_ALT, Path: test_alt_path, Body: []byte("alt")},
+		{Role: STAGED_PREGEOTAG, Path: pregeotag_path, Body: nil, Previous: []byte("pregeotag")},
+		{Role: STAGED_MAIN, Path: test_main_path, Body: []byte("main v2"), Previous: []byte("main")},
+		{Role: STAGED_RECORD, Path: bad_path, Body: []byte("record")},
+	}
+
+	err := wr.commit(context.Background(), staged)
+
+	if err == nil {
+		t.Fatalf("Expected commit to fail")
+	}
+
+	commit_err, ok := err.(*CommitError)
+
+	if !ok {
+		t.Fatalf("Expected a *CommitError, got %T (%v)", err, err)
+	}
+
+	if commit_err.Step != STAGED_RECORD || commit_err.Path != bad_path {
+		t.Errorf("Expected the %s document (%s) to fail, got %s (%s)", STAGED_RECORD, bad_path, commit_err.Step, commit_err.Path)
+	}
+
+	if len(commit_err.RollbackErrors) > 0 {
+		t.Errorf("Unexpected rollback errors, %v", commit_err.RollbackErrors)
+	}
+
+	expected := map[string][]byte{
+		test_alt_path:  nil,
+		pregeotag_path: []byte("pregeotag"),
+		test_main_path: []byte("main"),
+	}
+
+	for path, e := range expected {
+
+		body := readTestFeature(t, root, path)
+
+		if !bytes.Equal(body, e) {
+			t.Errorf("Expected %s to be rolled back to '%s', got '%s'", path, e, body)
+		}
+	}
+
+	// the journal entry is removed once everything has been rolled back
+
+	matches, err := filepath.Glob(filepath.Join(journal, "*"+JOURNAL_EXTENSION))
+
+	if err != nil {
+		t.Fatalf("Failed to list journal entries, %v", err)
+	}
+
+	if len(matches) != 0 {
+		t.Errorf("Expected the journal to be empty, found %v", matches)
+	}
+}
+
+func TestSameFeature(t *testing.T) {
+
+	previous := `{"type":"Feature","properties":{"wof:lastmodified":1,"wof:belongsto":[1,2],"geotag:request_id":"a","geotag:author":"alice"},"geometry":{"type":"Point","coordinates":[0,0]}}`
+
+	tests := []struct {
+		Name    string
+		Current string
+		Same    bool
+	}{
+		{"Reformatted", "{\n  \"type\": \"Feature\",\n  \"properties\": {\"wof:lastmodified\":1,\"wof:belongsto\":[1,2],\"geotag:request_id\":\"a\",\"geotag:author\":\"alice\"},\n  \"geometry\": {\"type\":\"Point\",\"coordinates\":[0,0]}\n}", true},
+		{"Last modified and request ID", `{"type":"Feature","properties":{"wof:lastmodified":2,"wof:belongsto":[1,2],"geotag:request_id":"b","geotag:author":"alice"},"geometry":{"type":"Point","coordinates":[0,0]}}`, true},
+		{"Belongs to order", `{"type":"Feature","properties":{"wof:lastmodified":1,"wof:belongsto":[2,1],"geotag:request_id":"a","geotag:author":"alice"},"geometry":{"type":"Point","coordinates":[0,0]}}`, true},
+		{"Author", `{"type":"Feature","properties":{"wof:lastmodified":1,"wof:belongsto":[1,2],"geotag:request_id":"a","geotag:author":"bob"},"geometry":{"type":"Point","coordinates":[0,0]}}`, false},
+		{"Geometry", `{"type":"Feature","properties":{"wof:lastmodified":1,"wof:belongsto":[1,2],"geotag:request_id":"a","geotag:author":"alice"},"geometry":{"type":"Point","coordinates":[0,1]}}`, false},
+	}
+
+	ignore := DefaultGeotagNames().requestProperties()
+
+	for _, test := range tests {
+
+		same, err := SameFeature([]byte(previous), []byte(test.Current), ignore...)
+
+		if err != nil {
+			t.Fatalf("%s: failed to compare features, %v", test.Name, err)
+		}
+
+		if same != test.Same {
+			t.Errorf("%s: expected %t, got %t", test.Name, test.Same, same)
+		}
+	}
+
+	same, _ := SameFeature(nil, []byte(previous))
+
+	if same {
+		t.Errorf("Expected a new feature to be different")
+	}
+}
+
+func TestWriteFeatureUnchanged(t *testing.T) {
+
+	root, cleanup := newTestRoot(t)
+	defer cleanup()
+
+	writeTestFeature(t, root, test_main_path, readTestRecord(t))
+
+	wr := newTestWriter(t, root, url.Values{"update": []string{"1"}})
+
+	tests := []struct {
+		Provenance *Provenance
+		Status     string
+	}{
+		{&Provenance{Author: "alice", RequestId: "1"}, STATUS_WRITTEN},
+		{&Provenance{Author: "alice", RequestId: "2"}, STATUS_UNCHANGED},
+		{&Provenance{Author: "bob", RequestId: "3"}, STATUS_WRITTEN},
+		{&Provenance{Author: "bob", RequestId: "4", ImageURL: "https://example.com/image.jpg"}, STATUS_WRITTEN},
+	}
+
+	for i, test := range tests {
+
+		ctx, _ := SetProvenanceWithContext(context.Background(), test.Provenance)
+
+		report, err := writeTestGeotagWithReport(ctx, t, wr)
+
+		if err != nil {
+			t.Fatalf("Failed to write geotag %d, %v", i, err)
+		}
+
+		if report.Status != test.Status {
+			t.Errorf("Expected write %d to be %s, got %s", i, test.Status, report.Status)
+		}
+
+		alt_body := readTestFeature(t, root, test_alt_path)
+
+		author := gjson.GetBytes(alt_body, "properties.geotag:author").String()
+
+		if author != test.Provenance.Author {
+			t.Errorf("Expected write %d to have author %s, got %s", i, test.Provenance.Author, author)
+		}
+	}
+}
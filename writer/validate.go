@@ -0,0 +1,276 @@
+package writer
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sfomuseum/go-geojson-geotag"
+	"github.com/sfomuseum/go-www-geotag/geo"
+	"math"
+	"strings"
+)
+
+// ValidationOptions defines the bounds used to validate geotag features.
+type ValidationOptions struct {
+	// MaxDistance is the maximum allowed distance (in meters). If 0 then distances are not bounded.
+	MaxDistance float64
+	// MinAngle is the (exclusive) minimum allowed field of view angle.
+	MinAngle float64
+	// MaxAngle is the (inclusive) maximum allowed field of view angle.
+	MaxAngle float64
+}
+
+// Violation describes a single reason why a geotag feature failed validation.
+type Violation struct {
+	Property string `json:"property"`
+	Message  string `json:"message"`
+}
+
+// ValidationError is returned when a geotag feature, or the parameters of a request, fail validation.
+type ValidationError struct {
+	Violations []*Violation `json:"violations"`
+}
+
+func (e *ValidationError) Error() string {
+
+	messages := make([]string, len(e.Violations))
+
+	for i, v := range e.Violations {
+		messages[i] = fmt.Sprintf("%s %s", v.Property, v.Message)
+	}
+
+	return fmt.Sprintf("Invalid geotag request: %s", strings.Join(messages, "; "))
+}
+
+// newParameterError returns a *ValidationError for the invalid request parameter name.
+func newParameterError(name string, message string, args ...interface{}) *ValidationError {
+
+	v_err := &ValidationError{
+		Violations: make([]*Violation, 0),
+	}
+
+	v_err.add(fmt.Sprintf("parameters.%s", name), message, args...)
+	return v_err
+}
+
+func (e *ValidationError) add(property string, message string, args ...interface{}) {
+
+	v := &Violation{
+		Property: property,
+		Message:  fmt.Sprintf(message, args...),
+	}
+
+	e.Violations = append(e.Violations, v)
+}
+
+// DefaultValidationOptions returns a ValidationOptions instance with no maximum distance and
+// angles bounded by (0, 360].
+func DefaultValidationOptions() *ValidationOptions {
+
+	opts := &ValidationOptions{
+		MaxDistance: 0.0,
+		MinAngle:    0.0,
+		MaxAngle:    360.0,
+	}
+
+	return opts
+}
+
+// ValidateGeotagFeature ensures that f is not nil and has a GeometryCollection geometry containing a valid
+// Point (the point of view) followed by a valid two-point LineString (the horizon line), with a non-zero
+// length and no point in common with the point of view, and that its angle, bearing and distance properties
+// are within the bounds defined by opts. If not a *ValidationError listing all the violations is returned.
+func ValidateGeotagFeature(f *geotag.GeotagFeature, opts *ValidationOptions) error {
+
+	v_err := &ValidationError{
+		Violations: make([]*Violation, 0),
+	}
+
+	if f == nil {
+		v_err.add("feature", "must not be null")
+		return v_err
+	}
+
+	if f.Geometry.Type != "GeometryCollection" {
+		v_err.add("geometry.type", "must be GeometryCollection")
+	}
+
+	// camera is the (valid) point of view, if present, which the horizon line must not coincide with
+
+	var camera []float64
+
+	pov, err := decodeGeometry(f.Geometry.Geometries[0])
+
+	switch {
+	case err != nil:
+		v_err.add("geometry.geometries.0", "is not a valid geometry, %v", err)
+	case pov.Type != "Point":
+		v_err.add("geometry.geometries.0.type", "must be Point")
+	default:
+
+		var coords []float64
+
+		err := json.Unmarshal(pov.Coordinates, &coords)
+
+		if err != nil || len(coords) != 2 {
+			v_err.add("geometry.geometries.0.coordinates", "must be a [longitude, latitude] pair")
+		} else if validateCoordinate(v_err, "geometry.geometries.0.coordinates", coords) {
+			camera = coords
+		}
+	}
+
+	hl, err := decodeGeometry(f.Geometry.Geometries[1])
+
+	switch {
+	case err != nil:
+		v_err.add("geometry.geometries.1", "is not a valid geometry, %v", err)
+	case hl.Type != "LineString":
+		v_err.add("geometry.geometries.1.type", "must be LineString")
+	default:
+
+		var coords [][]float64
+
+		err := json.Unmarshal(hl.Coordinates, &coords)
+
+		if err != nil || len(coords) != 2 {
+			v_err.add("geometry.geometries.1.coordinates", "must contain exactly two [longitude, latitude] pairs")
+			break
+		}
+
+		for i, c := range coords {
+
+			path := fmt.Sprintf("geometry.geometries.1.coordinates.%d", i)
+
+			if len(c) != 2 {
+				v_err.add(path, "must be a [longitude, latitude] pair")
+				continue
+			}
+
+			if !validateCoordinate(v_err, path, c) {
+				continue
+			}
+
+			if camera != nil && c[0] == camera[0] && c[1] == camera[1] {
+				v_err.add(path, "must not be the same as the point of view")
+			}
+		}
+
+		if len(coords[0]) == 2 && len(coords[1]) == 2 && coords[0][0] == coords[1][0] && coords[0][1] == coords[1][1] {
+			v_err.add("geometry.geometries.1.coordinates", "must not be a zero-length line")
+		}
+	}
+
+	props := f.Properties
+
+	if !isFinite(props.Angle) || props.Angle <= opts.MinAngle || props.Angle > opts.MaxAngle {
+		v_err.add("properties.angle", "must be greater than %v and less than or equal to %v", opts.MinAngle, opts.MaxAngle)
+	}
+
+	if !isFinite(props.Bearing) || props.Bearing < -360.0 || props.Bearing > 360.0 {
+		v_err.add("properties.bearing", "must be between -360 and 360")
+	}
+
+	if !isFinite(props.Distance) || props.Distance <= 0.0 {
+		v_err.add("properties.distance", "must be greater than 0")
+	} else if opts.MaxDistance > 0.0 && props.Distance > opts.MaxDistance {
+		v_err.add("properties.distance", "must be less than or equal to %v", opts.MaxDistance)
+	}
+
+	if len(v_err.Violations) > 0 {
+		return v_err
+	}
+
+	return nil
+}
+
+// ValidateDistanceBand ensures that distance, the computed distance from the point of view to the target,
+// is greater than 0, that the minimum and maximum focus distances in band are not negative, that the minimum
+// distance is less than the maximum distance (or distance if there is no maximum distance) and that the
+// maximum distance is within the bounds defined by opts. If not a *ValidationError listing all the violations
+// is returned.
+func ValidateDistanceBand(distance float64, band *DistanceBand, opts *ValidationOptions) error {
+
+	v_err := &ValidationError{
+		Violations: make([]*Violation, 0),
+	}
+
+	if !isFinite(distance) || distance <= 0.0 {
+		v_err.add("geometry.geometries.1", "must not be centered on the point of view")
+	}
+
+	if !isFinite(band.Min) || band.Min < 0.0 {
+		v_err.add("properties.distance_min", "must be greater than or equal to 0")
+	}
+
+	if !isFinite(band.Max) || band.Max < 0.0 {
+		v_err.add("properties.distance_max", "must be greater than or equal to 0")
+	} else if opts.MaxDistance > 0.0 && band.Max > opts.MaxDistance {
+		v_err.add("properties.distance_max", "must be less than or equal to %v", opts.MaxDistance)
+	}
+
+	max := band.Max
+
+	if max == 0.0 {
+		max = distance
+	}
+
+	if band.Min > 0.0 && band.Min >= max {
+		v_err.add("properties.distance_min", "must be less than %v", max)
+	}
+
+	if len(v_err.Violations) > 0 {
+		return v_err
+	}
+
+	return nil
+}
+
+type rawGeometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+func decodeGeometry(raw interface{}) (*rawGeometry, error) {
+
+	enc, err := json.Marshal(raw)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var g *rawGeometry
+
+	err = json.Unmarshal(enc, &g)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if g == nil {
+		return nil, fmt.Errorf("missing geometry")
+	}
+
+	return g, nil
+}
+
+// validateCoordinate adds a violation to v_err for each invalid value of coords and returns true if
+// coords is valid.
+func validateCoordinate(v_err *ValidationError, path string, coords []float64) bool {
+
+	ok := true
+
+	if !isFinite(coords[0]) || !geo.IsValidLongitude(coords[0]) {
+		v_err.add(path, "has an invalid longitude")
+		ok = false
+	}
+
+	if !isFinite(coords[1]) || !geo.IsValidLatitude(coords[1]) {
+		v_err.add(path, "has an invalid latitude")
+		ok = false
+	}
+
+	return ok
+}
+
+func isFinite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
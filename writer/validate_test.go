@@ -0,0 +1,118 @@
+package writer
+
+import (
+	"github.com/sfomuseum/go-geojson-geotag"
+	"testing"
+)
+
+// test_geotag is the geotag feature in fixtures/test.geojson.
+const test_geotag string = `{"type":"Feature","properties":{"angle":20,"bearing":-106.54919973541514,"distance":4209.290541392863},"geometry":{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[-122.36640930175783,37.61888804488137]},{"type":"LineString","coordinates":[[-122.40979705757145,37.60170454665891],[-122.41460335611261,37.614495404514365]]}]}}`
+
+func newTestGeotagFeature(t *testing.T, body string) *geotag.GeotagFeature {
+
+	f, err := geotag.NewGeotagFeature([]byte(body))
+
+	if err != nil {
+		t.Fatalf("Failed to parse geotag feature, %v", err)
+	}
+
+	return f
+}
+
+func TestValidateGeotagFeature(t *testing.T) {
+
+	opts := DefaultValidationOptions()
+	opts.MaxDistance = 10000.0
+
+	tests := []struct {
+		Name       string
+		Body       string
+		Properties []string
+	}{
+		{
+			Name: "Valid",
+			Body: test_geotag,
+		},
+		{
+			Name:       "Null",
+			Body:       `null`,
+			Properties: []string{"feature"},
+		},
+		{
+			Name:       "Not a geometry collection",
+			Body:       `{"type":"Feature","properties":{"angle":20,"bearing":0,"distance":100},"geometry":{"type":"Point","geometries":[{"type":"Point","coordinates":[0,0]},{"type":"LineString","coordinates":[[1,1],[1,2]]}]}}`,
+			Properties: []string{"geometry.type"},
+		},
+		{
+			Name:       "Invalid point of view",
+			Body:       `{"type":"Feature","properties":{"angle":20,"bearing":0,"distance":100},"geometry":{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[200,0]},{"type":"LineString","coordinates":[[1,1],[1,2]]}]}}`,
+			Properties: []string{"geometry.geometries.0.coordinates"},
+		},
+		{
+			Name:       "Horizon is not a line",
+			Body:       `{"type":"Feature","properties":{"angle":20,"bearing":0,"distance":100},"geometry":{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[0,0]},{"type":"Point","coordinates":[1,1]}]}}`,
+			Properties: []string{"geometry.geometries.1.type"},
+		},
+		{
+			Name:       "Horizon touches the point of view",
+			Body:       `{"type":"Feature","properties":{"angle":20,"bearing":0,"distance":100},"geometry":{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[0,0]},{"type":"LineString","coordinates":[[0,0],[1,2]]}]}}`,
+			Properties: []string{"geometry.geometries.1.coordinates.0"},
+		},
+		{
+			Name:       "Zero-length horizon",
+			Body:       `{"type":"Feature","properties":{"angle":20,"bearing":0,"distance":100},"geometry":{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[0,0]},{"type":"LineString","coordinates":[[1,1],[1,1]]}]}}`,
+			Properties: []string{"geometry.geometries.1.coordinates"},
+		},
+		{
+			Name:       "Invalid properties",
+			Body:       `{"type":"Feature","properties":{"angle":0,"bearing":400,"distance":20000},"geometry":{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[0,0]},{"type":"LineString","coordinates":[[1,1],[1,2]]}]}}`,
+			Properties: []string{"properties.angle", "properties.bearing", "properties.distance"},
+		},
+	}
+
+	for _, test := range tests {
+
+		err := ValidateGeotagFeature(newTestGeotagFeature(t, test.Body), opts)
+
+		if len(test.Properties) == 0 {
+
+			if err != nil {
+				t.Errorf("%s: unexpected error, %v", test.Name, err)
+			}
+
+			continue
+		}
+
+		v_err, ok := err.(*ValidationError)
+
+		if !ok {
+			t.Errorf("%s: expected a *ValidationError, got %v", test.Name, err)
+			continue
+		}
+
+		if len(v_err.Violations) != len(test.Properties) {
+			t.Errorf("%s: expected %d violations, got %v", test.Name, len(test.Properties), v_err)
+			continue
+		}
+
+		for i, v := range v_err.Violations {
+
+			if v.Property != test.Properties[i] {
+				t.Errorf("%s: expected violation %d to be for %s, got %s", test.Name, i, test.Properties[i], v.Property)
+			}
+		}
+	}
+}
+
+func TestParameterError(t *testing.T) {
+
+	err := newParameterError("dryrun", "must be a boolean, %s", "maybe")
+
+	if len(err.Violations) != 1 || err.Violations[0].Property != "parameters.dryrun" {
+		t.Fatalf("Unexpected violations, %v", err.Violations)
+	}
+
+	if err.Error() != "Invalid geotag request: parameters.dryrun must be a boolean, maybe" {
+		t.Errorf("Unexpected error message '%s'", err.Error())
+	}
+}
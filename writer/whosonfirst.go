@@ -4,9 +4,13 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/paulmach/orb/geojson"
 	"github.com/sfomuseum/go-geojson-geotag"
+	"github.com/sfomuseum/go-www-geotag-whosonfirst/index"
+	"github.com/sfomuseum/go-www-geotag-whosonfirst/lock"
 	geotag_writer "github.com/sfomuseum/go-www-geotag/writer"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -17,18 +21,26 @@ import (
 	wof_uri "github.com/whosonfirst/go-whosonfirst-uri"
 	"github.com/whosonfirst/go-writer"
 	"io/ioutil"
-	_ "log"
+	"log"
 	"net/url"
+	"os"
 	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const GEOTAG_NS string = "geotag"
 const GEOTAG_SRC string = "geotag"
 const GEOTAG_LABEL string = "geotag-fov" // field of view
 
+var re_geotag_name *regexp.Regexp
+
 func init() {
 	ctx := context.Background()
 	geotag_writer.RegisterWriter(ctx, "whosonfirst", NewWhosOnFirstGeotagWriter)
+
+	re_geotag_name = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
 }
 
 // please put this in a common whosonfirst geojson/feature package
@@ -38,15 +50,44 @@ type WhosOnFirstAltFeature struct {
 	Id         int64                  `json:"id"`
 	Type       string                 `json:"type"`
 	Properties map[string]interface{} `json:"properties"`
+	Bbox       []float64              `json:"bbox,omitempty"`
 	Geometry   interface{}            `json:"geometry"`
 }
 
 type WhosOnFirstGeotagWriter struct {
 	geotag_writer.Writer
-	writer      writer.Writer
-	reader      reader.Reader
-	update      bool
-	geom_source string
+	writer             writer.Writer
+	writer_scheme      string
+	reader             reader.Reader
+	update             bool
+	dryrun             bool
+	geom_source        string
+	allowed_sources    map[string]bool
+	allowed_updates    map[bool]bool
+	allow_override     bool
+	validation         *ValidationOptions
+	bearing_tolerance  float64
+	distance_tolerance float64
+	fov                string
+	fov_segments       int
+	spatial_index      *index.SpatialIndex
+	parent_placetypes  []string
+	hierarchy_policy   string
+	depicts_enabled    bool
+	depicts_placetypes []string
+	alt_geometries     []string
+	delete_policy      string
+	update_rules       UpdateRules
+	property_map       PropertyMap
+	names              *GeotagNames
+	superseded_policy  string
+	locks              *lock.IdLocks
+	lockfile           bool
+	lock_timeout       time.Duration
+	lock_dir           string
+	lock_stale         time.Duration
+	journal            string
+	journal_recovery   string
 }
 
 func NewWhosOnFirstGeotagWriter(ctx context.Context, uri string) (geotag_writer.Writer, error) {
@@ -82,6 +123,12 @@ func NewWhosOnFirstGeotagWriter(ctx context.Context, uri string) (geotag_writer.
 		return nil, err
 	}
 
+	writer_u, err := url.Parse(writer_uri)
+
+	if err != nil {
+		return nil, err
+	}
+
 	wof_wr, err := writer.NewWriter(ctx, writer_uri)
 
 	if err != nil {
@@ -100,276 +147,1275 @@ func NewWhosOnFirstGeotagWriter(ctx context.Context, uri string) (geotag_writer.
 		update = true
 	}
 
-	geom_source := GEOTAG_SRC
+	dryrun := false
 
-	q_source := q.Get("source")
+	if q.Get("dryrun") == "1" {
+		dryrun = true
+	}
 
-	if q_source != "" {
+	validation := DefaultValidationOptions()
 
-		re, err := regexp.Compile(`^[a-zA-Z0-9_\-]+$`)
+	bearing_tolerance := DEFAULT_BEARING_TOLERANCE
+	distance_tolerance := DEFAULT_DISTANCE_TOLERANCE
 
-		if err != nil {
-			return nil, err
+	for k, ptr := range map[string]*float64{
+		"max_distance":       &validation.MaxDistance,
+		"min_angle":          &validation.MinAngle,
+		"max_angle":          &validation.MaxAngle,
+		"bearing_tolerance":  &bearing_tolerance,
+		"distance_tolerance": &distance_tolerance,
+	} {
+
+		str_v := q.Get(k)
+
+		if str_v == "" {
+			continue
 		}
 
-		if !re.MatchString(q_source) {
-			return nil, errors.New("Invalid source")
+		v, err := strconv.ParseFloat(str_v, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s parameter, %v", k, err)
 		}
 
-		geom_source = q_source
+		*ptr = v
 	}
 
-	wr := &WhosOnFirstGeotagWriter{
-		writer:      wof_wr,
-		reader:      wof_rd,
-		update:      update,
-		geom_source: geom_source,
-	}
+	fov := FOV_TRIANGLE
 
-	return wr, nil
-}
+	q_fov := q.Get("fov")
 
-func (wr *WhosOnFirstGeotagWriter) WriteFeature(ctx context.Context, uri string, geotag_f *geotag.GeotagFeature) error {
+	if q_fov != "" {
 
-	io_wr, err := geotag_writer.GetIOWriterFromContext(ctx)
+		switch q_fov {
+		case FOV_TRIANGLE, FOV_ARC:
+			fov = q_fov
+		default:
+			return nil, errors.New("Invalid fov parameter")
+		}
+	}
 
-	if err == nil {
+	fov_segments := DEFAULT_FOV_SEGMENTS
 
-		ctx, err = writer.SetIOWriterWithContext(ctx, io_wr)
+	q_segments := q.Get("segments")
+
+	if q_segments != "" {
+
+		fov_segments, err = strconv.Atoi(q_segments)
 
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("Invalid segments parameter, %v", err)
 		}
 
+		if fov_segments < 1 || fov_segments > 1024 {
+			return nil, errors.New("Invalid segments parameter")
+		}
 	}
 
-	// for local debugging
-	// uri = "1511948897"
+	var spatial_index *index.SpatialIndex
 
-	wof_id, uri_args, err := wof_uri.ParseURI(uri)
+	index_uri := q.Get("spatial_index")
 
-	if err != nil {
-		return err
+	if index_uri != "" {
+
+		index_uri, err = url.QueryUnescape(index_uri)
+
+		if err != nil {
+			return nil, err
+		}
+
+		spatial_index, err = index.NewSpatialIndex(ctx, index_uri)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create spatial index, %v", err)
+		}
 	}
 
-	if uri_args.IsAlternate {
-		return errors.New("Alt files are not supported yet")
+	parent_placetypes := make([]string, 0)
+
+	q_placetypes := q.Get("parent_placetypes")
+
+	if q_placetypes != "" {
+		parent_placetypes = strings.Split(q_placetypes, ",")
 	}
 
-	rel_path, err := wof_uri.Id2RelPath(wof_id)
+	hierarchy_policy := HIERARCHY_WARN
 
-	if err != nil {
-		return err
+	q_hierarchy := q.Get("hierarchy")
+
+	if q_hierarchy != "" {
+
+		switch q_hierarchy {
+		case HIERARCHY_WARN, HIERARCHY_REFUSE:
+			hierarchy_policy = q_hierarchy
+		default:
+			return nil, errors.New("Invalid hierarchy parameter")
+		}
 	}
 
-	main_fh, err := wr.reader.Read(ctx, rel_path)
+	depicts_enabled := false
 
-	if err != nil {
-		return err
+	if q.Get("depicts") == "1" {
+
+		if spatial_index == nil {
+			return nil, errors.New("depicts parameter requires a spatial_index parameter")
+		}
+
+		depicts_enabled = true
 	}
 
-	main_body, err := ioutil.ReadAll(main_fh)
+	depicts_placetypes := make([]string, 0)
 
-	if err != nil {
-		return err
+	q_depicts_placetypes := q.Get("depicts_placetypes")
+
+	if q_depicts_placetypes != "" {
+		depicts_placetypes = strings.Split(q_depicts_placetypes, ",")
 	}
 
-	repo_rsp := gjson.GetBytes(main_body, "properties.wof:repo")
+	alt_geometries := make([]string, 0)
 
-	if !repo_rsp.Exists() {
-		return errors.New("Missing wof:repo")
+	q_alt_geometries := q.Get("alt_geometries")
+
+	if q_alt_geometries != "" {
+
+		alt_geometries, err = ParseAltGeometries(q_alt_geometries)
+
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	main_repo := repo_rsp.String()
+	delete_policy := DELETE_REMOVE
 
-	//
+	q_delete := q.Get("delete")
 
-	pov, err := geotag_f.PointOfView()
+	if q_delete != "" {
 
-	if err != nil {
-		return err
+		switch q_delete {
+		case DELETE_REMOVE, DELETE_DEPRECATE:
+			delete_policy = q_delete
+		default:
+			return nil, errors.New("Invalid delete parameter")
+		}
 	}
 
-	tgt, err := geotag_f.Target()
+	update_rules := make(UpdateRules, 0)
 
-	if err != nil {
-		return err
+	q_rules := q.Get("rules")
+
+	if q_rules != "" {
+
+		rules, err := ParseUpdateRules(q_rules)
+
+		if err != nil {
+			return nil, err
+		}
+
+		update_rules = append(update_rules, rules...)
 	}
 
-	pov_coords := pov.Coordinates
-	tgt_coords := tgt.Coordinates
+	rules_file := q.Get("rules_file")
 
-	geotag_props := geotag_f.Properties
+	if rules_file != "" {
 
-	alt_props := map[string]interface{}{
-		"wof:id":                  wof_id,
-		"wof:repo":                main_repo,
-		"src:alt_label":           GEOTAG_LABEL,
-		"src:geom":                wr.geom_source,
-		"geotag:angle":            geotag_props.Angle,
-		"geotag:bearing":          geotag_props.Bearing,
-		"geotag:distance":         geotag_props.Distance,
-		"geotag:camera_longitude": pov_coords[0],
-		"geotag:camera_latitude":  pov_coords[1],
-		"geotag:target_longitude": tgt_coords[0],
-		"geotag:target_latitude":  tgt_coords[1],
+		rules_file, err = url.QueryUnescape(rules_file)
+
+		if err != nil {
+			return nil, err
+		}
+
+		rules, err := ReadUpdateRules(rules_file)
+
+		if err != nil {
+			return nil, err
+		}
+
+		update_rules = append(update_rules, rules...)
 	}
 
-	alt_geom, err := geotag_f.FieldOfView()
+	names, err := NewGeotagNames(q.Get("label"), q.Get("namespace"))
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	alt_feature := &WhosOnFirstAltFeature{
-		Type:       "Feature",
-		Id:         wof_id,
-		Properties: alt_props,
-		Geometry:   alt_geom,
-	}
+	property_map := NamespacedPropertyMap(names)
 
-	//
+	properties_file := q.Get("properties_file")
 
-	alt_body, err := FormatAltFeature(alt_feature)
+	if properties_file != "" {
 
-	if err != nil {
-		return err
+		properties_file, err = url.QueryUnescape(properties_file)
+
+		if err != nil {
+			return nil, err
+		}
+
+		property_map, err = ReadPropertyMap(properties_file)
+
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	alt_uri_geom := &wof_uri.AltGeom{
-		Source: GEOTAG_LABEL,
+	add_properties := q.Get("add_properties")
+
+	if add_properties != "" {
+
+		m, err := ParsePropertyMap(add_properties)
+
+		if err != nil {
+			return nil, err
+		}
+
+		property_map.Merge(m)
 	}
 
-	alt_uri_args := &wof_uri.URIArgs{
-		IsAlternate: true,
-		AltGeom:     alt_uri_geom,
+	remove_properties := q.Get("remove_properties")
+
+	if remove_properties != "" {
+		property_map.Remove(strings.Split(remove_properties, ",")...)
 	}
 
-	alt_uri, err := wof_uri.Id2RelPath(wof_id, alt_uri_args)
+	err = names.validatePropertyMap(property_map)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	alt_br := bytes.NewReader(alt_body)
-	alt_fh := ioutil.NopCloser(alt_br)
+	superseded_policy := SUPERSEDED_REFUSE
 
-	err = wr.writer.Write(ctx, alt_uri, alt_fh)
+	q_superseded := q.Get("superseded")
 
-	if err != nil {
-		return err
+	if q_superseded != "" {
+
+		switch q_superseded {
+		case SUPERSEDED_REFUSE, SUPERSEDED_FOLLOW:
+			superseded_policy = q_superseded
+		default:
+			return nil, errors.New("Invalid superseded parameter")
+		}
 	}
 
-	if wr.update {
+	lockfile := false
 
-		main_body, err = sjson.SetBytes(main_body, "geometry", pov)
+	if q.Get("lockfile") == "1" {
 
-		if err != nil {
-			return err
+		if writer_u.Scheme != "fs" {
+			return nil, errors.New("lockfile parameter is only supported by fs:// writers")
 		}
 
-		to_update := map[string]interface{}{
-			"lbl:longitude":           pov_coords[0],
-			"lbl:latitude":            pov_coords[1],
-			"geotag:camera_longitude": pov_coords[0],
-			"geotag:camera_latitude":  pov_coords[1],
-			"geotag:target_longitude": tgt_coords[0],
-			"geotag:target_latitude":  tgt_coords[1],
-			"geotag:angle":            geotag_props.Angle,
-			"src:geom":                wr.geom_source,
+		lockfile = true
+	}
+
+	lock_timeout := DEFAULT_LOCK_TIMEOUT
+	lock_stale := DEFAULT_LOCK_STALE
+
+	for k, ptr := range map[string]*time.Duration{
+		"lock_timeout": &lock_timeout,
+		"lock_stale":   &lock_stale,
+	} {
+
+		str_v := q.Get(k)
+
+		if str_v == "" {
+			continue
 		}
 
-		geom_alt := []string{
-			GEOTAG_LABEL,
+		v, err := strconv.Atoi(str_v)
+
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("Invalid %s parameter", k)
 		}
 
-		geom_alt_rsp := gjson.GetBytes(main_body, "properties.src:geom_alt")
+		*ptr = time.Duration(v) * time.Second
+	}
+
+	// a timeout of 0 would refuse every write, since the lock context would already be done
 
-		if geom_alt_rsp.Exists() {
+	if lock_timeout == 0 {
+		return nil, errors.New("Invalid lock_timeout parameter, must be greater than 0")
+	}
 
-			for _, r := range geom_alt_rsp.Array() {
+	lock_dir := q.Get("lock_dir")
 
-				if r.String() == GEOTAG_LABEL {
-					continue
-				}
+	if lock_dir != "" {
 
-				geom_alt = append(geom_alt, r.String())
-			}
+		if !lockfile {
+			return nil, errors.New("lock_dir parameter requires lockfile=1")
 		}
 
-		to_update["src:geom_alt"] = geom_alt
+		lock_dir, err = url.QueryUnescape(lock_dir)
 
-		for k, v := range to_update {
+		if err != nil {
+			return nil, err
+		}
 
-			path := fmt.Sprintf("properties.%s", k)
-			main_body, err = sjson.SetBytes(main_body, path, v)
+		err = os.MkdirAll(lock_dir, 0755)
 
-			if err != nil {
-				return err
-			}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create lock directory, %v", err)
 		}
+	}
+
+	journal := q.Get("journal")
 
-		// please refactor everything about whosonfirst/go-whosonfirst-export...
-		// (20200410/thisisaaronland)
+	if journal != "" {
 
-		ex_opts, err := export_options.NewDefaultOptions()
+		journal, err = url.QueryUnescape(journal)
 
 		if err != nil {
-			return err
+			return nil, err
+		}
+
+		err = os.MkdirAll(journal, 0755)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create journal directory, %v", err)
+		}
+	}
+
+	journal_recovery := JOURNAL_ROLLBACK
+
+	q_recovery := q.Get("journal_recovery")
+
+	if q_recovery != "" {
+
+		switch q_recovery {
+		case JOURNAL_ROLLBACK, JOURNAL_REPLAY:
+			journal_recovery = q_recovery
+		default:
+			return nil, errors.New("Invalid journal_recovery parameter")
+		}
+	}
+
+	geom_source := GEOTAG_SRC
+
+	q_source := q.Get("source")
+
+	if q_source != "" {
+
+		if !re_source.MatchString(q_source) {
+			return nil, errors.New("Invalid source")
 		}
 
-		var main_buf bytes.Buffer
-		main_wr := bufio.NewWriter(&main_buf)
+		geom_source = q_source
+	}
+
+	allowed_sources := make(map[string]bool)
+
+	q_allowed_sources := q.Get("allowed_sources")
 
-		err = export.Export(main_body, ex_opts, main_wr)
+	if q_allowed_sources != "" {
+
+		allowed_sources, err = ParseAllowedSources(q_allowed_sources)
 
 		if err != nil {
-			return err
+			return nil, err
 		}
+	}
+
+	allowed_updates := make(map[bool]bool)
+
+	q_allowed_updates := q.Get("allowed_updates")
 
-		main_wr.Flush()
-		main_br := bytes.NewReader(main_buf.Bytes())
-		main_fh := ioutil.NopCloser(main_br)
+	if q_allowed_updates != "" {
 
-		err = wr.writer.Write(ctx, rel_path, main_fh)
+		allowed_updates, err = ParseAllowedUpdates(q_allowed_updates)
 
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	return nil
-}
+	allow_override := false
 
-func (wr *WhosOnFirstGeotagWriter) Close(ctx context.Context) error {
-	return nil
+	switch q.Get("allow_override") {
+	case "", "0":
+		// pass
+	case "1":
+		allow_override = true
+	default:
+		return nil, errors.New("Invalid allow_override parameter")
+	}
+
+	wr := &WhosOnFirstGeotagWriter{
+		writer:             wof_wr,
+		writer_scheme:      writer_u.Scheme,
+		reader:             wof_rd,
+		update:             update,
+		dryrun:             dryrun,
+		geom_source:        geom_source,
+		allowed_sources:    allowed_sources,
+		allowed_updates:    allowed_updates,
+		allow_override:     allow_override,
+		validation:         validation,
+		bearing_tolerance:  bearing_tolerance,
+		distance_tolerance: distance_tolerance,
+		fov:                fov,
+		fov_segments:       fov_segments,
+		spatial_index:      spatial_index,
+		parent_placetypes:  parent_placetypes,
+		hierarchy_policy:   hierarchy_policy,
+		depicts_enabled:    depicts_enabled,
+		depicts_placetypes: depicts_placetypes,
+		alt_geometries:     alt_geometries,
+		delete_policy:      delete_policy,
+		update_rules:       update_rules,
+		property_map:       property_map,
+		names:              names,
+		superseded_policy:  superseded_policy,
+		locks:              lock.NewIdLocks(),
+		lockfile:           lockfile,
+		lock_timeout:       lock_timeout,
+		lock_dir:           lock_dir,
+		lock_stale:         lock_stale,
+		journal:            journal,
+		journal_recovery:   journal_recovery,
+	}
+
+	return wr, nil
 }
 
-func FormatAltFeature(f *WhosOnFirstAltFeature) ([]byte, error) {
+func (wr *WhosOnFirstGeotagWriter) WriteFeature(ctx context.Context, uri string, geotag_f *geotag.GeotagFeature) error {
 
-	// please standardize on a common whosonfirst geojson/feature package
-	// (20200413/thisisaaronland)
+	err := ValidateGeotagFeature(geotag_f, wr.validation)
 
-	ff := &format.Feature{
-		Type:       f.Type,
-		ID:         f.Id,
-		Properties: f.Properties,
-		Geometry:   f.Geometry,
+	if err != nil {
+		return err
 	}
 
-	body, err := format.FormatFeature(ff)
+	feature_body, err := GetFeatureBodyFromContext(ctx)
 
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	// TODO: Add omitempty hooks for bbox in go-whosonfirst-feature
+	band, err := DistanceBandFromFeatureBody(feature_body)
+
+	if err != nil {
+		return err
+	}
+
+	// the distance band is validated against the computed distance to the target, since that is the
+	// distance used to derive the field of view, so the geometry is derived before anything is read
 
-	body, err = sjson.DeleteBytes(body, "bbox")
+	geotag_geom, err := newGeotagGeometry(geotag_f, band, wr.bearing_tolerance, wr.distance_tolerance)
 
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return body, nil
+	err = ValidateDistanceBand(geotag_geom.Distance, band, wr.validation)
+
+	if err != nil {
+		return err
+	}
+
+	io_wr, err := geotag_writer.GetIOWriterFromContext(ctx)
+
+	if err == nil {
+
+		ctx, err = writer.SetIOWriterWithContext(ctx, io_wr)
+
+		if err != nil {
+			return err
+		}
+
+	}
+
+	// for local debugging
+	// uri = "1511948897"
+
+	wof_id, uri_args, err := wof_uri.ParseURI(uri)
+
+	if err != nil {
+		return err
+	}
+
+	params, err := GetRequestParametersFromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	geotag_name := params.Get("geotag")
+
+	geotag_label, err := wr.names.AltLabel(uri_args, geotag_name)
+
+	if err != nil {
+		return err
+	}
+
+	// the unnamed geotag is the one that drives updates to the record by default
+	// but any (named) geotag can be designated as the "primary" geotag
+
+	is_primary := geotag_name == ""
+
+	dryrun := wr.dryrun
+
+	switch params.Get("dryrun") {
+	case "":
+		// pass
+	case "1":
+		dryrun = true
+	case "0":
+		dryrun = false
+	default:
+		return newParameterError("dryrun", "must be 0 or 1")
+	}
+
+	switch params.Get("primary") {
+	case "":
+		// pass
+	case "1":
+		is_primary = true
+	case "0":
+		is_primary = false
+	default:
+		return newParameterError("primary", "must be 0 or 1")
+	}
+
+	geom_source, err := wr.requestSource(params)
+
+	if err != nil {
+		return err
+	}
+
+	update, err := wr.requestUpdate(params)
+
+	if err != nil {
+		return err
+	}
+
+	override, err := wr.requestOverride(params)
+
+	if err != nil {
+		return err
+	}
+
+	provenance, err := GetProvenanceFromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	provenance_props := provenance.Properties(wr.names, time.Now())
+
+	rel_path, err := wof_uri.Id2RelPath(wof_id)
+
+	if err != nil {
+		return err
+	}
+
+	unlock, err := wr.lock(ctx, wof_id, rel_path)
+
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	main_body, err := wr.readFeature(ctx, rel_path)
+
+	if err != nil {
+		return err
+	}
+
+	// superseded records may be replaced by the record that supersedes them, in which
+	// case wof_id, rel_path and main_body all refer to the superseding record from here on
+
+	wof_id, rel_path, main_body, lifecycle, unlock_superseding, err := wr.resolveLockedLifecycle(ctx, wof_id, main_body, uri_args.IsAlternate, override)
+
+	if err != nil {
+		return err
+	}
+
+	defer unlock_superseding()
+
+	// if we are geotagging an alternate geometry then the record we are
+	// reading from (and optionally updating) is the alt file and the geotag
+	// itself is written as an alt file derived from the alt file's label
+
+	record_path := rel_path
+	record_body := main_body
+
+	if uri_args.IsAlternate {
+
+		record_path, err = wof_uri.Id2RelPath(wof_id, uri_args)
+
+		if err != nil {
+			return err
+		}
+
+		record_body, err = wr.readFeature(ctx, record_path)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	repo_rsp := gjson.GetBytes(record_body, "properties.wof:repo")
+
+	if !repo_rsp.Exists() {
+		repo_rsp = gjson.GetBytes(main_body, "properties.wof:repo")
+	}
+
+	if !repo_rsp.Exists() {
+		return errors.New("Missing wof:repo")
+	}
+
+	main_repo := repo_rsp.String()
+
+	alt_uri_geom := &wof_uri.AltGeom{
+		Source: geotag_label,
+	}
+
+	alt_uri_args := &wof_uri.URIArgs{
+		IsAlternate: true,
+		AltGeom:     alt_uri_geom,
+	}
+
+	alt_uri, err := wof_uri.Id2RelPath(wof_id, alt_uri_args)
+
+	if err != nil {
+		return err
+	}
+
+	alt_previous := wr.readPrevious(ctx, alt_uri)
+
+	// ensure that neither the record nor the geotag have changed since the client last read them
+
+	var etag_record []byte
+
+	if uri_args.IsAlternate {
+		etag_record = record_body
+	}
+
+	etag := GeotagETag(main_body, etag_record, alt_previous)
+
+	err = checkPreconditions(ctx, wof_id, etag, alt_previous != nil)
+
+	if err != nil {
+		return err
+	}
+
+	// geotags keep the time they were first created when they are rewritten (unless they were deprecated)
+
+	created_k := wr.names.Property(PROVENANCE_CREATED)
+	created_rsp := gjson.GetBytes(alt_previous, fmt.Sprintf("properties.%s", created_k))
+
+	if created_rsp.Exists() && !gjson.GetBytes(alt_previous, "properties.edtf:deprecated").Exists() {
+		provenance_props[created_k] = created_rsp.Int()
+	}
+
+	//
+
+	pov, err := geotag_f.PointOfView()
+
+	if err != nil {
+		return err
+	}
+
+	pov_coords := geotag_geom.Camera
+	tgt_coords := geotag_geom.Target
+
+	alt_props := map[string]interface{}{
+		"wof:id":                              wof_id,
+		"wof:repo":                            main_repo,
+		"src:alt_label":                       geotag_label,
+		"src:geom":                            geom_source,
+		wr.names.Property("angle"):            geotag_geom.Angle,
+		wr.names.Property("bearing"):          geotag_geom.Bearing,
+		wr.names.Property("distance"):         geotag_geom.Distance,
+		wr.names.Property("client_bearing"):   geotag_geom.ClientBearing,
+		wr.names.Property("client_distance"):  geotag_geom.ClientDistance,
+		wr.names.Property("camera_longitude"): pov_coords[0],
+		wr.names.Property("camera_latitude"):  pov_coords[1],
+		wr.names.Property("target_longitude"): tgt_coords[0],
+		wr.names.Property("target_latitude"):  tgt_coords[1],
+		wr.names.Property("horizon"):          geotag_geom.Horizon,
+	}
+
+	fov, err := geotag_geom.FieldOfView(wr.fov, wr.fov_segments)
+
+	if err != nil {
+		return err
+	}
+
+	// values are the geotag values that can be assigned to the record's properties (see PropertyMap)
+	// and absent are the optional values that are known not to exist for this geotag
+
+	values := map[string]interface{}{
+		VALUE_CAMERA_LONGITUDE: pov_coords[0],
+		VALUE_CAMERA_LATITUDE:  pov_coords[1],
+		VALUE_TARGET_LONGITUDE: tgt_coords[0],
+		VALUE_TARGET_LATITUDE:  tgt_coords[1],
+		VALUE_ANGLE:            geotag_geom.Angle,
+		VALUE_BEARING:          geotag_geom.Bearing,
+		VALUE_DISTANCE:         geotag_geom.Distance,
+		VALUE_CLIENT_BEARING:   geotag_geom.ClientBearing,
+		VALUE_CLIENT_DISTANCE:  geotag_geom.ClientDistance,
+	}
+
+	absent := map[string]bool{}
+
+	optional_props := map[string]interface{}{}
+
+	if geotag_geom.DistanceMin > 0.0 {
+		optional_props[wr.names.Property("distance_min")] = geotag_geom.DistanceMin
+		values[VALUE_DISTANCE_MIN] = geotag_geom.DistanceMin
+	} else {
+		absent[VALUE_DISTANCE_MIN] = true
+	}
+
+	if geotag_geom.DistanceMax > 0.0 {
+		optional_props[wr.names.Property("distance_max")] = geotag_geom.DistanceMax
+		values[VALUE_DISTANCE_MAX] = geotag_geom.DistanceMax
+	} else {
+		absent[VALUE_DISTANCE_MAX] = true
+	}
+
+	if wr.depicts_enabled {
+
+		depicts, target_id, err := wr.depicts(ctx, wof_id, fov, geotag_geom.Target)
+
+		if err != nil {
+			return err
+		}
+
+		if len(depicts) > 0 {
+			optional_props["wof:depicts"] = depicts
+			values[VALUE_DEPICTS] = depicts
+		} else {
+			absent[VALUE_DEPICTS] = true
+		}
+
+		if target_id != -1 {
+			optional_props[wr.names.Property("target_wof_id")] = target_id
+			values[VALUE_TARGET_WOF_ID] = target_id
+		} else {
+			absent[VALUE_TARGET_WOF_ID] = true
+		}
+	}
+
+	for k, v := range optional_props {
+		alt_props[k] = v
+	}
+
+	if len(geotag_geom.Disagreements) > 0 {
+
+		log.Printf("[WARNING] Client and computed values for %s disagree (%s): bearing %f (client) %f (computed), distance %f (client) %f (computed)", uri, strings.Join(geotag_geom.Disagreements, ", "), geotag_geom.ClientBearing, geotag_geom.Bearing, geotag_geom.ClientDistance, geotag_geom.Distance)
+
+		alt_props[wr.names.Property("disagreements")] = geotag_geom.Disagreements
+	}
+
+	alt_geom := geojson.NewGeometry(fov)
+
+	spatial_props, alt_bbox, err := SpatialProperties(fov)
+
+	if err != nil {
+		return err
+	}
+
+	for k, v := range spatial_props {
+		alt_props[k] = v
+	}
+
+	for k, v := range provenance_props {
+		alt_props[k] = v
+	}
+
+	alt_feature := &WhosOnFirstAltFeature{
+		Type:       "Feature",
+		Id:         wof_id,
+		Properties: alt_props,
+		Bbox:       alt_bbox,
+		Geometry:   alt_geom,
+	}
+
+	//
+
+	alt_body, err := FormatAltFeature(alt_feature)
+
+	if err != nil {
+		return err
+	}
+
+	// stage all the documents to write before writing anything
+
+	staged := []*stagedFeature{
+		&stagedFeature{
+			Role:     STAGED_ALT,
+			Path:     alt_uri,
+			Body:     alt_body,
+			Previous: alt_previous,
+		},
+	}
+
+	geometry_props := map[string]interface{}{
+		"wof:id":                       wof_id,
+		"wof:repo":                     main_repo,
+		"src:geom":                     geom_source,
+		wr.names.Property("fov_label"): geotag_label,
+	}
+
+	for k, v := range provenance_props {
+		geometry_props[k] = v
+	}
+
+	geometries, geometry_labels, err := wr.stageAltGeometries(ctx, wof_id, uri_args, geotag_name, geotag_geom, geometry_props)
+
+	if err != nil {
+		return err
+	}
+
+	staged = append(staged, geometries...)
+
+	if update {
+
+		main_previous := main_body
+
+		// non-primary geotags are only registered with the main record
+
+		if is_primary {
+
+			placetype_rsp := gjson.GetBytes(record_body, "properties.wof:placetype")
+
+			if !placetype_rsp.Exists() {
+				placetype_rsp = gjson.GetBytes(main_body, "properties.wof:placetype")
+			}
+
+			geom_type := gjson.GetBytes(record_body, "geometry.type").String()
+
+			action := wr.update_rules.Action(placetype_rsp.String(), geom_type)
+
+			if action == UPDATE_REFUSE {
+
+				return &RefusedError{
+					Id:     wof_id,
+					Reason: fmt.Sprintf("Update rules do not allow %s records with %s geometries to be updated", placetype_rsp.String(), geom_type),
+				}
+			}
+
+			property_map := PropertyMap{}
+			property_map.Merge(wr.property_map)
+
+			if action == UPDATE_PROPERTIES {
+				property_map.Remove("lbl:*")
+			}
+
+			to_update, to_remove := property_map.Apply(values, absent)
+			to_update[wr.names.Property("primary_label")] = geotag_label
+
+			for k, v := range provenance_props {
+				to_update[k] = v
+			}
+
+			// provenance properties from a previous geotag that are not set by this one
+
+			for _, k := range wr.names.provenanceProperties() {
+
+				_, ok := to_update[k]
+
+				if !ok {
+					to_remove = append(to_remove, k)
+				}
+			}
+
+			if uri_args.IsAlternate {
+
+				// alt files have their label and camera properties updated but
+				// their geometry (and src:geom) are left untouched; the main record
+				// only needs to know that there is a new alt file
+
+				record_previous := record_body
+
+				record_body, err = savePreviousProperties(record_body, wr.names, false)
+
+				if err != nil {
+					return err
+				}
+
+				record_body, err = saveMappedProperties(record_body, wr.names, to_update, to_remove)
+
+				if err != nil {
+					return err
+				}
+
+				record_body, err = setProperties(record_body, to_update)
+
+				if err != nil {
+					return err
+				}
+
+				record_body, err = removeProperties(record_body, to_remove)
+
+				if err != nil {
+					return err
+				}
+
+				record_body, err = FormatAltBody(record_body)
+
+				if err != nil {
+					return err
+				}
+
+				staged = append(staged, &stagedFeature{
+					Role:     STAGED_RECORD,
+					Path:     record_path,
+					Body:     record_body,
+					Previous: record_previous,
+				})
+
+			} else {
+
+				replace_geometry := action == UPDATE_GEOMETRY
+
+				main_body, err = savePreviousProperties(main_body, wr.names, replace_geometry)
+
+				if err != nil {
+					return err
+				}
+
+				main_body, err = saveMappedProperties(main_body, wr.names, to_update, to_remove)
+
+				if err != nil {
+					return err
+				}
+
+				if replace_geometry {
+
+					// the original geometry is preserved as its own alt file before it is replaced
+
+					pregeotag, pregeotag_body, err := wr.stagePregeotag(ctx, wof_id, main_repo, main_body)
+
+					if err != nil {
+						return err
+					}
+
+					main_body = pregeotag_body
+
+					if pregeotag != nil {
+						staged = append([]*stagedFeature{pregeotag}, staged...)
+					}
+
+					main_body, err = sjson.SetBytes(main_body, "geometry", pov)
+
+					if err != nil {
+						return err
+					}
+
+					to_update["src:geom"] = geom_source
+				}
+
+				main_body, err = setProperties(main_body, to_update)
+
+				if err != nil {
+					return err
+				}
+
+				main_body, err = removeProperties(main_body, to_remove)
+
+				if err != nil {
+					return err
+				}
+
+				if replace_geometry && wr.spatial_index != nil {
+
+					main_body, err = wr.updateHierarchy(ctx, wof_id, main_body, pov_coords)
+
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		// labels are prepended so the geotag's own label comes first, followed by its additional geometries
+
+		for i := len(geometry_labels) - 1; i >= 0; i-- {
+
+			main_body, err = appendGeomAlt(main_body, geometry_labels[i])
+
+			if err != nil {
+				return err
+			}
+		}
+
+		main_body, err = appendGeomAlt(main_body, geotag_label)
+
+		if err != nil {
+			return err
+		}
+
+		main_body, err = ExportFeature(main_body)
+
+		if err != nil {
+			return err
+		}
+
+		staged = append(staged, &stagedFeature{
+			Role:     STAGED_MAIN,
+			Path:     rel_path,
+			Body:     main_body,
+			Previous: main_previous,
+		})
+	}
+
+	if dryrun {
+		return wr.writePreview(ctx, staged, lifecycle)
+	}
+
+	// skip documents that would not change (apart from their wof:lastmodified and per-request provenance properties)
+
+	to_write, unchanged, err := skipUnchanged(staged, wr.names.requestProperties()...)
+
+	if err != nil {
+		return err
+	}
+
+	err = wr.commit(ctx, to_write)
+
+	if err != nil {
+		return err
+	}
+
+	for _, f := range staged {
+
+		switch f.Role {
+		case STAGED_ALT:
+			alt_body = f.Body
+		case STAGED_RECORD:
+			record_body = f.Body
+		case STAGED_MAIN:
+			main_body = f.Body
+		}
+	}
+
+	if uri_args.IsAlternate {
+		etag_record = record_body
+	}
+
+	status := STATUS_WRITTEN
+
+	if len(to_write) == 0 {
+		status = STATUS_UNCHANGED
+	}
+
+	report := &WhosOnFirstGeotagReport{
+		Id:        wof_id,
+		Status:    status,
+		ETag:      GeotagETag(main_body, etag_record, alt_body),
+		RequestId: provenance.RequestId,
+		Unchanged: unchanged,
+		Lifecycle: lifecycle,
+	}
+
+	return wr.writeReport(ctx, report)
+}
+
+// GeotagAltLabel returns the alt label, using the default GeotagNames, for the geotag named name (which
+// may be empty) of the record (or alternate geometry) described by uri_args.
+func GeotagAltLabel(uri_args *wof_uri.URIArgs, name string) (string, error) {
+	return DefaultGeotagNames().AltLabel(uri_args, name)
+}
+
+func (wr *WhosOnFirstGeotagWriter) readFeature(ctx context.Context, path string) ([]byte, error) {
+
+	fh, err := wr.reader.Read(ctx, path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer fh.Close()
+
+	return ioutil.ReadAll(fh)
+}
+
+// readPrevious returns the current body of the document at path or nil if it can not be read
+// (for example because it does not exist yet).
+func (wr *WhosOnFirstGeotagWriter) readPrevious(ctx context.Context, path string) []byte {
+
+	body, err := wr.readFeature(ctx, path)
+
+	if err != nil {
+		return nil
+	}
+
+	return body
+}
+
+func (wr *WhosOnFirstGeotagWriter) writeFeature(ctx context.Context, path string, body []byte) error {
+
+	br := bytes.NewReader(body)
+	fh := ioutil.NopCloser(br)
+
+	return wr.writer.Write(ctx, path, fh)
+}
+
+// removeFeature removes the document at path. Currently this is only supported for "fs://" writers.
+func (wr *WhosOnFirstGeotagWriter) removeFeature(ctx context.Context, path string) error {
+
+	switch wr.writer_scheme {
+	case "fs":
+		return os.Remove(wr.writer.URI(path))
+	default:
+		return fmt.Errorf("Removing files is not supported by %s:// writers", wr.writer_scheme)
+	}
+}
+
+func (wr *WhosOnFirstGeotagWriter) Close(ctx context.Context) error {
+	return nil
+}
+
+func FormatAltFeature(f *WhosOnFirstAltFeature) ([]byte, error) {
+
+	// please standardize on a common whosonfirst geojson/feature package
+	// (20200413/thisisaaronland)
+
+	ff := &format.Feature{
+		Type:       f.Type,
+		ID:         f.Id,
+		Properties: f.Properties,
+		Geometry:   f.Geometry,
+	}
+
+	if len(f.Bbox) > 0 {
+		ff.Bbox = f.Bbox
+	}
+
+	body, err := format.FormatFeature(ff)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// TODO: Add omitempty hooks for bbox in go-whosonfirst-feature
+
+	if ff.Bbox == nil {
+
+		body, err = sjson.DeleteBytes(body, "bbox")
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return body, nil
+}
+
+// FormatAltBody formats an existing (encoded) alt file, or record, according to the Who's On First
+// formatting rules without updating any of its properties.
+func FormatAltBody(body []byte) ([]byte, error) {
+
+	var ff *format.Feature
+
+	err := json.Unmarshal(body, &ff)
+
+	if err != nil {
+		return nil, err
+	}
+
+	body, err = format.FormatFeature(ff)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if ff.Bbox == nil {
+
+		body, err = sjson.DeleteBytes(body, "bbox")
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return body, nil
+}
+
+// ExportFeature exports a main (non-alt) Who's On First record using the default
+// go-whosonfirst-export options.
+func ExportFeature(body []byte) ([]byte, error) {
+
+	// please refactor everything about whosonfirst/go-whosonfirst-export...
+	// (20200410/thisisaaronland)
+
+	ex_opts, err := export_options.NewDefaultOptions()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	wr := bufio.NewWriter(&buf)
+
+	err = export.Export(body, ex_opts, wr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	wr.Flush()
+
+	return buf.Bytes(), nil
+}
+
+func setProperties(body []byte, to_update map[string]interface{}) ([]byte, error) {
+
+	var err error
+
+	for k, v := range to_update {
+
+		path := fmt.Sprintf("properties.%s", k)
+		body, err = sjson.SetBytes(body, path, v)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return body, nil
+}
+
+func removeProperties(body []byte, to_remove []string) ([]byte, error) {
+
+	var err error
+
+	for _, k := range to_remove {
+
+		path := fmt.Sprintf("properties.%s", k)
+		body, err = sjson.DeleteBytes(body, path)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return body, nil
+}
+
+func appendGeomAlt(body []byte, label string) ([]byte, error) {
+
+	geom_alt := []string{
+		label,
+	}
+
+	geom_alt_rsp := gjson.GetBytes(body, "properties.src:geom_alt")
+
+	if geom_alt_rsp.Exists() {
+
+		for _, r := range geom_alt_rsp.Array() {
+
+			if r.String() == label {
+				continue
+			}
+
+			geom_alt = append(geom_alt, r.String())
+		}
+	}
+
+	return sjson.SetBytes(body, "properties.src:geom_alt", geom_alt)
 }
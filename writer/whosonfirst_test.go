@@ -0,0 +1,74 @@
+package writer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/sfomuseum/go-geojson-geotag"
+	geotag_writer "github.com/sfomuseum/go-www-geotag/writer"
+	"io/ioutil"
+	"testing"
+)
+
+// readTestRecord returns the body of the record in fixtures/1234567.geojson.
+func readTestRecord(t *testing.T) []byte {
+
+	body, err := ioutil.ReadFile("../fixtures/1234567.geojson")
+
+	if err != nil {
+		t.Fatalf("Failed to read record, %v", err)
+	}
+
+	return body
+}
+
+// readTestGeotag returns the geotag feature in fixtures/test.geojson.
+func readTestGeotag(t *testing.T) *geotag.GeotagFeature {
+
+	body, err := ioutil.ReadFile("../fixtures/test.geojson")
+
+	if err != nil {
+		t.Fatalf("Failed to read geotag feature, %v", err)
+	}
+
+	return newTestGeotagFeature(t, string(body))
+}
+
+// writeTestGeotag writes the geotag feature in fixtures/test.geojson for the record 1234567 using wr.
+func writeTestGeotag(t *testing.T, ctx context.Context, wr *WhosOnFirstGeotagWriter) {
+
+	err := wr.WriteFeature(ctx, "1234567", readTestGeotag(t))
+
+	if err != nil {
+		t.Fatalf("Failed to write geotag, %v", err)
+	}
+}
+
+// writeTestGeotagWithReport writes the geotag feature in fixtures/test.geojson for the record 1234567 using wr
+// and returns the report of the write.
+func writeTestGeotagWithReport(ctx context.Context, t *testing.T, wr *WhosOnFirstGeotagWriter) (*WhosOnFirstGeotagReport, error) {
+
+	var buf bytes.Buffer
+
+	ctx, err := geotag_writer.SetIOWriterWithContext(ctx, &buf)
+
+	if err != nil {
+		t.Fatalf("Failed to assign report writer, %v", err)
+	}
+
+	err = wr.WriteFeature(ctx, "1234567", readTestGeotag(t))
+
+	if err != nil {
+		return nil, err
+	}
+
+	var report *WhosOnFirstGeotagReport
+
+	err = json.Unmarshal(buf.Bytes(), &report)
+
+	if err != nil {
+		t.Fatalf("Failed to decode report, %v", err)
+	}
+
+	return report, nil
+}